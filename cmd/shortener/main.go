@@ -2,8 +2,8 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	route "github.com/go-chi/chi/v5"
@@ -12,12 +12,17 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/JohnnyConstantin/urlshort/internal/app"
+	"github.com/JohnnyConstantin/urlshort/internal/blocklist"
 	"github.com/JohnnyConstantin/urlshort/internal/certificates"
 	"github.com/JohnnyConstantin/urlshort/internal/config"
+	"github.com/JohnnyConstantin/urlshort/internal/idgen"
 	"github.com/JohnnyConstantin/urlshort/internal/store"
+	"github.com/JohnnyConstantin/urlshort/internal/urlnorm"
 )
 
 var sugar zap.SugaredLogger
@@ -59,10 +64,22 @@ func main() {
 
 	// Загружаем конфиг JSON. Логика перезаписывания с флагами инкапсулирована внутри. Переменные окружения и
 	// так грузятся после этого
-	config.LoadJSONConfig()
+	if err = config.LoadJSONConfig(); err != nil {
+		sugar.Fatalf("Invalid JSON config: %v", err)
+	}
 
 	// Вынес загрузку переменных окружения в отдельную функцию
-	loadEnvs()
+	if err = loadEnvs(); err != nil {
+		sugar.Fatalf("Invalid environment variables: %v", err)
+	}
+
+	// Подкоманда migrate up|down|status работает только со схемой БД, сервер при этом не запускается
+	if flag.Arg(0) == "migrate" {
+		if err = runMigrate(flag.Args()[1:]); err != nil {
+			sugar.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
 
 	// записываем в лог, что сервер запускается
 	sugar.Infow(
@@ -71,18 +88,90 @@ func main() {
 	)
 
 	// Создание и применение конфигурации. Если ошибка - выход с ненулевым кодом. Ошибка при этом логируется в sugar
-	s.DB, err = storageDecider()
+	s.Repo, err = storageDecider()
 	if err != nil {
 		panic(err)
 	}
 
+	// Журнал файлового хранилища можно компактить по SIGHUP, не дожидаясь порогов
+	if fileStore, ok := s.Repo.(*store.FileStore); ok {
+		s.Compactor = store.StartCompactor(fileStore, sugar)
+	}
+
+	// Каждая операция с хранилищем ограничена по времени, включая фоновые воркеры
+	s.Repo = store.NewTimeoutRepository(s.Repo, store.Timeouts{
+		Read:  config.Options.ReadTimeout,
+		Write: config.Options.WriteTimeout,
+	})
+
+	// Хранилища в памяти и в файле и так отвечают из памяти, кэш нужен только перед базами данных
+	storageType := config.GetStorageConfig().StorageType
+	if config.Options.CacheSize > 0 && storageType != config.StorageMemory && storageType != config.StorageFile {
+		cache := store.NewResolveCache(config.Options.CacheSize, config.Options.CacheTTL, config.Options.CacheNegTTL)
+		s.Repo = store.NewCachedRepository(s.Repo, cache)
+
+		// Счетчики попаданий доступны вместе с pprof на localhost:6060/debug/vars
+		expvar.Publish("resolve_cache", expvar.Func(func() any { return cache.Stats() }))
+
+		// Другие инстансы меняют ту же базу, их изменения приходят через NOTIFY
+		if storageType == config.StorageDB {
+			s.Listener = store.StartChangeListener(config.Options.DSN, cache, sugar)
+		}
+	}
+	// Неподходящий список схем - ошибка конфигурации, с ней сервер не стартует
+	urls, err := urlnorm.New(config.Options.AllowedSchemes, config.Options.StripTracking)
+	if err != nil {
+		sugar.Fatalf("Invalid allowed schemes: %v", err)
+	}
+	handler.SetURLNormalizer(urls)
+
+	// Ссылки на сам сервис раскрываются или отклоняются, чтобы из них нельзя было собрать петлю
+	bases := append([]string{config.Options.BaseAddress}, config.Options.SelfURLs...)
+	selfLinks, err := app.NewSelfLinks(bases, config.Options.SelfLinks, config.Options.MaxLinkDepth)
+	if err != nil {
+		sugar.Fatalf("Invalid self links config: %v", err)
+	}
+	handler.SetSelfLinks(selfLinks)
+
+	interstitial, err := app.NewInterstitial(config.Options.ForceInterstitial, config.Options.TrustedDomains)
+	if err != nil {
+		sugar.Fatalf("Invalid trusted domains: %v", err)
+	}
+	handler.SetInterstitial(interstitial)
+
+	if config.Options.BlocklistPath != "" {
+		status := config.Options.BlocklistStatus
+		if status != http.StatusUnavailableForLegalReasons && status != http.StatusGone {
+			sugar.Fatalf("Invalid blocklist status %d: must be 451 or 410", status)
+		}
+		s.Blocklist, err = blocklist.Start(config.Options.BlocklistPath, blocklist.ReloadInterval, sugar)
+		if err != nil {
+			sugar.Fatalf("Cannot load blocklist: %v", err)
+		}
+		handler.SetBlocklist(s.Blocklist, status)
+	}
+
+	handler.SetRepository(s.Repo) // Хранилище выбирается один раз, дальше хендлеры работают только с интерфейсом
+
+	s.Clicks = store.StartClickRecorder(s.Repo, sugar)
+	handler.SetClickRecorder(s.Clicks)
+
+	// Воркеры подхватывают и задачи, не завершенные до прошлой остановки
+	s.Deletions = store.StartDeletionQueue(s.Repo, store.DeletionWorkers, sugar)
+	handler.SetDeletionQueue(s.Deletions)
+
+	// Истекшие ссылки и без очистки отдают 410, воркер лишь не дает им копиться в хранилище
+	if config.Options.ReapInterval > 0 {
+		s.Reaper = store.StartReaper(s.Repo, config.Options.ReapInterval, sugar)
+	}
+
 	// Запускаем HTTP-сервер для профилирования в отдельной горутине
 	go func() {
 		log.Println(http.ListenAndServe("localhost:6060", nil))
 	}()
 
 	// Вынес создание роутов в отдельную функцию
-	createHandlers(s.DB, router, sugar, handler)
+	createHandlers(router, sugar, handler)
 
 	startListenAndServe(s, router)
 }
@@ -155,24 +244,24 @@ func waitShutdown(errChan chan error, shutdownChan chan struct{}, sugar zap.Suga
 	}
 }
 
-func createHandlers(db *sql.DB, router *route.Mux, sugar zap.SugaredLogger, handler *app.Handler) {
+func createHandlers(router *route.Mux, sugar zap.SugaredLogger, handler *app.Handler) {
 	//Накидываем хендлеры на роуты
 	router.Route("/", func(r route.Router) {
 		r.Post("/",
 			app.GzipHandle( // Сжатие
-				app.WithLogging(db,
+				app.WithLogging(
 					handler.WithAuth( // Логирование, прокидываем в него регистратор логов sugar
 						handler.PostHandler), sugar))) // Сам хендлер
 		r.Route("/api", func(r route.Router) {
 			r.Route("/shorten", func(r route.Router) {
 				r.Post("/",
 					app.GzipHandle( // Сжатие
-						app.WithLogging(db, // Логирование, прокидываем в него регистратор логов sugar
+						app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
 							handler.WithAuth( // Добавляем аутентификацию
 								handler.PostHandler), sugar))) // Сам хендлер
 				r.Post("/batch",
 					app.GzipHandle( // Сжатие
-						app.WithLogging(db,
+						app.WithLogging(
 							handler.WithAuth( // Логирование, прокидываем в него регистратор логов sugar
 								handler.PostHandlerMultiple), sugar))) // Сам хендлер
 
@@ -180,30 +269,54 @@ func createHandlers(db *sql.DB, router *route.Mux, sugar zap.SugaredLogger, hand
 			r.Route("/user", func(r route.Router) {
 				r.Delete("/urls",
 					app.GzipHandle( // Сжатие
-						app.WithLogging(db, // Логирование, прокидываем в него регистратор логов sugar
+						app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
 							handler.WithAuth( // Добавляем аутентификацию
 								handler.DeleteHandlerMultiple), sugar))) // Сам хендлер
 				r.Get(
 					"/urls",
 					app.GzipHandle( // Сжатие
-						app.WithLogging(db, // Логирование, прокидываем в него регистратор логов sugar
+						app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
 							handler.WithAuth( //Добавляем аутентификацию
 								handler.GetHandlerMultiple), sugar))) // Сам хендлер
+				r.Get(
+					"/urls/{id}/stats",
+					app.GzipHandle( // Сжатие
+						app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
+							handler.WithAuth( //Добавляем аутентификацию
+								handler.GetStatsHandler), sugar))) // Сам хендлер
+				r.Get(
+					"/urls/{id}/visits",
+					app.GzipHandle( // Сжатие
+						app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
+							handler.WithAuth( //Добавляем аутентификацию
+								handler.GetVisitsHandler), sugar))) // Сам хендлер
+				r.Get(
+					"/jobs/{id}",
+					app.GzipHandle( // Сжатие
+						app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
+							handler.WithAuth( //Добавляем аутентификацию
+								handler.GetJobHandler), sugar))) // Сам хендлер
 
 			})
 		})
 		r.Get("/{id}",
 			app.GzipHandle( // Сжатие
-				app.WithLogging(db, // Логирование, прокидываем в него регистратор логов sugar
+				app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
 					handler.GetHandler, sugar))) // Сам хендлер
+		r.Get("/{id}/qr",
+			app.GzipHandle( // Сжатие
+				app.WithLogging( // Логирование, прокидываем в него регистратор логов sugar
+					handler.GetQRHandler, sugar))) // Сам хендлер
 		r.Get("/ping",
-			app.WithLogging(db,
+			app.WithLogging(
 				handler.PingDBHandler, sugar)) // Сам хендлер
 	})
 }
 
-func loadEnvs() {
-	//Подгружаем переменные окружения при наличии
+// loadEnvs подгружает переменные окружения при наличии. Возвращает ошибки всех переменных, которые не удалось разобрать
+func loadEnvs() error {
+	var errs []error
+
 	envA, ok := os.LookupEnv("SERVER_ADDRESS")
 	if ok && envA != "" {
 		config.Options.Address = envA
@@ -217,11 +330,114 @@ func loadEnvs() {
 		config.Options.FileToWrite = envC
 	}
 
+	errs = append(errs, parseEnv("FILE_COMPACT_MIN_SIZE", parseInt64, &config.Options.FileCompactSize))
+
+	errs = append(errs, parseEnv("FILE_COMPACT_GARBAGE_RATIO", parseFloat, &config.Options.FileCompactRatio))
+
 	envD, ok := os.LookupEnv("DATABASE_DSN")
 	if ok && envD != "" {
 		config.Options.DSN = envD
 	}
 
+	envS, ok := os.LookupEnv("SQLITE_PATH")
+	if ok && envS != "" {
+		config.Options.SQLitePath = envS
+	}
+
+	envBolt, ok := os.LookupEnv("BOLT_PATH")
+	if ok && envBolt != "" {
+		config.Options.BoltPath = envBolt
+	}
+
+	envIDStrategy, ok := os.LookupEnv("ID_STRATEGY")
+	if ok && envIDStrategy != "" {
+		config.Options.IDStrategy = envIDStrategy
+	}
+
+	errs = append(errs, parseEnv("ID_LENGTH", strconv.Atoi, &config.Options.IDLength))
+
+	envIDAlphabet, ok := os.LookupEnv("ID_ALPHABET")
+	if ok && envIDAlphabet != "" {
+		config.Options.IDAlphabet = envIDAlphabet
+	}
+
+	errs = append(errs, parseEnv("REAP_INTERVAL", time.ParseDuration, &config.Options.ReapInterval))
+
+	errs = append(errs, parseEnv("CACHE_SIZE", strconv.Atoi, &config.Options.CacheSize))
+
+	errs = append(errs, parseEnv("CACHE_TTL", time.ParseDuration, &config.Options.CacheTTL))
+
+	errs = append(errs, parseEnv("CACHE_NEGATIVE_TTL", time.ParseDuration, &config.Options.CacheNegTTL))
+
+	envReplicas, ok := os.LookupEnv("DATABASE_REPLICA_DSN")
+	if ok && envReplicas != "" {
+		config.Options.ReplicaDSNs = config.SplitList(envReplicas)
+	}
+
+	errs = append(errs, parseEnv("READ_AFTER_WRITE_WINDOW", time.ParseDuration, &config.Options.ReadWindow))
+
+	errs = append(errs, parseEnv("DB_READ_TIMEOUT", time.ParseDuration, &config.Options.ReadTimeout))
+
+	errs = append(errs, parseEnv("DB_WRITE_TIMEOUT", time.ParseDuration, &config.Options.WriteTimeout))
+
+	envPgxpool, ok := os.LookupEnv("DB_PGXPOOL")
+	if ok && (strings.ToLower(envPgxpool) == "true" || envPgxpool == "1") {
+		config.Options.DBPgxpool = true
+	}
+
+	errs = append(errs, parseEnv("DB_MAX_OPEN_CONNS", strconv.Atoi, &config.Options.DBMaxOpenConns))
+
+	errs = append(errs, parseEnv("DB_MAX_IDLE_CONNS", strconv.Atoi, &config.Options.DBMaxIdleConns))
+
+	errs = append(errs, parseEnv("DB_CONN_MAX_LIFETIME", time.ParseDuration, &config.Options.DBConnMaxLifetime))
+
+	errs = append(errs, parseEnv("DB_CONN_MAX_IDLE_TIME", time.ParseDuration, &config.Options.DBConnMaxIdleTime))
+
+	errs = append(errs, parseEnv("DB_HEALTH_CHECK_PERIOD", time.ParseDuration, &config.Options.DBHealthCheck))
+
+	errs = append(errs, parseEnv("DB_STATEMENT_CACHE", strconv.Atoi, &config.Options.DBStatementCache))
+
+	errs = append(errs, parseEnv("DB_RETRY_BUDGET", time.ParseDuration, &config.Options.DBRetryBudget))
+
+	envSchemes, ok := os.LookupEnv("ALLOWED_SCHEMES")
+	if ok && envSchemes != "" {
+		config.Options.AllowedSchemes = config.SplitList(envSchemes)
+	}
+
+	envStrip, ok := os.LookupEnv("STRIP_TRACKING_PARAMS")
+	if ok && (strings.ToLower(envStrip) == "true" || envStrip == "1") {
+		config.Options.StripTracking = true
+	}
+
+	envBlocklist, ok := os.LookupEnv("BLOCKLIST_PATH")
+	if ok && envBlocklist != "" {
+		config.Options.BlocklistPath = envBlocklist
+	}
+
+	errs = append(errs, parseEnv("BLOCKLIST_STATUS", strconv.Atoi, &config.Options.BlocklistStatus))
+
+	envSelfURLs, ok := os.LookupEnv("SELF_URLS")
+	if ok && envSelfURLs != "" {
+		config.Options.SelfURLs = config.SplitList(envSelfURLs)
+	}
+
+	envSelfLinks, ok := os.LookupEnv("SELF_LINKS")
+	if ok && envSelfLinks != "" {
+		config.Options.SelfLinks = envSelfLinks
+	}
+
+	errs = append(errs, parseEnv("MAX_LINK_DEPTH", strconv.Atoi, &config.Options.MaxLinkDepth))
+
+	envInterstitial, ok := os.LookupEnv("FORCE_INTERSTITIAL")
+	if ok && (strings.ToLower(envInterstitial) == "true" || envInterstitial == "1") {
+		config.Options.ForceInterstitial = true
+	}
+
+	envTrusted, ok := os.LookupEnv("TRUSTED_DOMAINS")
+	if ok && envTrusted != "" {
+		config.Options.TrustedDomains = config.SplitList(envTrusted)
+	}
+
 	envE, ok := os.LookupEnv("SECRET_KEY")
 	if ok && envE != "" {
 		config.Options.SecretKey = envE
@@ -232,9 +448,42 @@ func loadEnvs() {
 		// окружения должна содержать true или 1 (перевожу в lowercase, чтобы обработать True и TRUE)
 		config.Options.EnableHTTPS = true
 	}
+
+	return errors.Join(errs...)
+}
+
+// parseEnv разбирает переменную окружения name в dst. Пустая или отсутствующая переменная оставляет dst как есть
+func parseEnv[T any](name string, parse func(string) (T, error), dst *T) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func parseInt64(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
 }
 
-func storageDecider() (*sql.DB, error) {
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func storageDecider() (store.Repository, error) {
+	// Генератор идентификаторов нужен хранилищу с первой же вставки, поэтому настраиваем его до открытия
+	generator, err := idgen.New(config.Options.IDStrategy, config.Options.IDLength, config.Options.IDAlphabet)
+	if err != nil {
+		sugar.Error("Invalid short ID generator settings")
+		return nil, err
+	}
+	store.SetIDGenerator(generator)
+
 	// Вызываем резолвер способа хранения данных
 	config.CreateStorageConfig()
 	cfg := config.GetStorageConfig()
@@ -243,11 +492,12 @@ func storageDecider() (*sql.DB, error) {
 	switch cfg.StorageType {
 	case config.StorageDB:
 		db := store.DB{}
-		err := db.OpenDB(config.Options.DSN)
+		err := db.OpenDB(config.Options.DSN, poolConfig())
 		if err != nil {
 			sugar.Error("Could not connect to database")
 			return nil, err
 		}
+		db.SetRetryBudget(config.Options.DBRetryBudget)
 
 		// Создаем таблицу (если ее нет)
 		if err = db.InitDB(); err != nil {
@@ -255,27 +505,83 @@ func storageDecider() (*sql.DB, error) {
 			return nil, err
 		}
 
+		// Реплики только для чтения, схему и миграции ведет основная база
+		if len(config.Options.ReplicaDSNs) > 0 {
+			if err = db.OpenReplicas(config.Options.ReplicaDSNs, config.Options.ReadWindow, sugar); err != nil {
+				sugar.Error("Could not connect to read replicas")
+				return nil, err
+			}
+		}
+
+		// Состояние пула доступно вместе с pprof на localhost:6060/debug/vars
+		expvar.Publish("db_pool", expvar.Func(func() any { return db.PoolStats() }))
+
 		sugar.Infow("Using PostgreSQL as a storage",
-			"DSN", config.Options.DSN)
+			"DSN", config.Options.DSN,
+			"pool", db.PoolStats().Driver,
+			"replicas", len(config.Options.ReplicaDSNs))
 
-		return db.DB, nil
+		return &db, nil
+
+	case config.StorageSQLite:
+		db, err := store.OpenSQLite(cfg.FilePath)
+		if err != nil {
+			sugar.Error("Could not open SQLite database")
+			return nil, err
+		}
+
+		sugar.Infow("Using SQLite as a storage",
+			"file", cfg.FilePath)
+
+		return db, nil
+
+	case config.StorageBolt:
+		db, err := store.OpenBolt(cfg.FilePath, cfg.ImportPath, sugar)
+		if err != nil {
+			sugar.Error("Could not open bbolt database")
+			return nil, err
+		}
+
+		sugar.Infow("Using bbolt as a storage",
+			"file", cfg.FilePath)
+
+		return db, nil
 
 	case config.StorageFile:
 		sugar.Infow("Using file as a storage",
 			"file", config.Options.FileToWrite)
 
+		ratio := config.Options.FileCompactRatio
+		if ratio < 0 || ratio > 1 || config.Options.FileCompactSize < 0 {
+			return nil, fmt.Errorf("invalid file compaction thresholds: size %d, garbage ratio %v",
+				config.Options.FileCompactSize, ratio)
+		}
+
 		// Подгружаем URL из файла-хранилища в память-хранилище
-		// При большой нагрузке это так себе решение, потому что съедим кучу оперативы, но для PoC - acceptable :)
-		// Вариант с "постоянно дергать файл на Read/Write операции" без использования in-Memory показался совсем варварским
-		err := app.LoadURLsFromFile(config.Options.FileToWrite, sugar)
+		fileStore, err := store.OpenFileStore(config.Options.FileToWrite, sugar)
 		if err != nil {
 			return nil, err
 		}
+		fileStore.SetCompaction(config.Options.FileCompactSize, ratio)
+
+		return fileStore, nil
 	default:
-		//Только логируем, никаких доп.действий не требуется, все реализовано через проверку StorageType в целевых функциях
 		sugar.Infow("Using memory storage (no persistence)")
+		return store.NewMemoryStore(), nil
+	}
+}
+
+// poolConfig собирает настройки пула соединений с PostgreSQL из опций
+func poolConfig() store.PoolConfig {
+	return store.PoolConfig{
+		Native:            config.Options.DBPgxpool,
+		MaxOpen:           config.Options.DBMaxOpenConns,
+		MaxIdle:           config.Options.DBMaxIdleConns,
+		MaxLifetime:       config.Options.DBConnMaxLifetime,
+		MaxIdleTime:       config.Options.DBConnMaxIdleTime,
+		HealthCheckPeriod: config.Options.DBHealthCheck,
+		StatementCache:    config.Options.DBStatementCache,
 	}
-	return nil, nil
 }
 
 func printBuildInfo() {
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/JohnnyConstantin/urlshort/internal/config"
+	"github.com/JohnnyConstantin/urlshort/internal/store"
+)
+
+const migrateUsage = "usage: shortener -d <dsn> migrate up|down [steps]|status"
+
+// runMigrate выполняет подкоманду migrate: up применяет все недостающие миграции,
+// down откатывает последние steps миграций (по умолчанию одну), status выводит состояние каждой миграции
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if config.Options.DSN == "" {
+		return errors.New("database DSN is not set, use -d or DATABASE_DSN")
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid steps %q: %s", args[1], migrateUsage)
+		}
+		steps = n
+	}
+
+	db := store.DB{}
+	if err := db.OpenDB(config.Options.DSN, poolConfig()); err != nil {
+		return err
+	}
+	defer func(db *store.DB) {
+		err := db.Close()
+		if err != nil {
+			return
+		}
+	}(&db)
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		reverted, err := db.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			status := "pending"
+			if state.Applied() {
+				status = "applied at " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", state.Version, state.Name, status)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Ограничения на пользовательский alias. Верхняя граница совпадает с размером колонки short_url в PostgreSQL
+const (
+	aliasMinLength = 3
+	aliasMaxLength = 64
+)
+
+// aliasRe допустимые символы alias: те же, что безопасны в пути URL и разрешены генераторам идентификаторов
+var aliasRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAliases первые сегменты путей сервиса и служебные имена, которые нельзя занять ссылкой
+//
+//nolint:gochecknoglobals
+var reservedAliases = map[string]bool{
+	"api":     true,
+	"ping":    true,
+	"debug":   true,
+	"health":  true,
+	"metrics": true,
+	"admin":   true,
+	"static":  true,
+}
+
+// errInvalidAlias ошибка валидации alias, текст отдается клиенту
+var errInvalidAlias = errors.New("invalid alias")
+
+// validateAlias проверяет длину, набор символов и зарезервированные слова. Занятость проверяет хранилище
+func validateAlias(alias string) error {
+	if len(alias) < aliasMinLength || len(alias) > aliasMaxLength {
+		return fmt.Errorf("%w: length must be between %d and %d characters", errInvalidAlias, aliasMinLength, aliasMaxLength)
+	}
+	if !aliasRe.MatchString(alias) {
+		return fmt.Errorf("%w: only latin letters, digits, '-' and '_' are allowed", errInvalidAlias)
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return fmt.Errorf("%w: %q is reserved", errInvalidAlias, alias)
+	}
+	return nil
+}
+
+// aliasTakenMessage текст ответа 409 для занятого alias
+func aliasTakenMessage(alias string) string {
+	return fmt.Sprintf("alias %q is already taken", alias)
+}
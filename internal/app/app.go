@@ -3,14 +3,18 @@ package app
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/JohnnyConstantin/urlshort/internal/blocklist"
+	"github.com/JohnnyConstantin/urlshort/internal/store"
 )
 
 // Server объект сервера, имеющий хендлер и роутер
@@ -18,9 +22,21 @@ type Server struct {
 	Handler    *Handler
 	Router     *Router
 	HTTPServer *http.Server
-	DB         *sql.DB
+	Repo       store.Repository      // Хранилище ссылок, выбирается один раз при старте
+	Reaper     *store.Reaper         // Очистка истекших ссылок (опционально)
+	Clicks     *store.ClickRecorder  // Фоновая запись переходов (опционально)
+	Deletions  *store.DeletionQueue  // Воркеры очереди удаления
+	Listener   *store.ChangeListener // Сброс кэша по уведомлениям PostgreSQL (опционально)
+	Blocklist  *blocklist.Blocklist  // Перечитывание списка запрещенных доменов (опционально)
+	Compactor  *store.Compactor      // Компакция файлового хранилища по SIGHUP (опционально)
+
+	cancelRequests  context.CancelFunc // Отменяет контексты запросов, не завершившихся за время graceful shutdown
+	shutdownTimeout time.Duration      // Сколько ждать завершения запросов, 0 - DefaultShutdownTimeout
 }
 
+// DefaultShutdownTimeout сколько Shutdown ждет завершения начатых запросов
+const DefaultShutdownTimeout = 10 * time.Second
+
 // NewServer Инициализирует сервер с пустым хендлером и роутером
 func (s *Server) NewServer() *Server {
 	serv := &Server{
@@ -33,24 +49,30 @@ func (s *Server) NewServer() *Server {
 
 // Start запускает HTTP сервер и возвращает канал для ожидания завершения
 func (s *Server) Start(addr string, router *chi.Mux) error {
-	s.HTTPServer = &http.Server{
-		Addr:    addr,
-		Handler: router,
-	}
+	s.HTTPServer = s.newHTTPServer(addr, router)
 
 	return s.HTTPServer.ListenAndServe()
 }
 
 // StartTLS запускает HTTPS сервер и возвращает канал для ожидания завершения
 func (s *Server) StartTLS(addr, certFile, keyFile string, router *chi.Mux) error {
-	s.HTTPServer = &http.Server{
-		Addr:    addr,
-		Handler: router,
-	}
+	s.HTTPServer = s.newHTTPServer(addr, router)
 
 	return s.HTTPServer.ListenAndServeTLS(certFile, keyFile)
 }
 
+// newHTTPServer создает HTTP сервер, контексты запросов которого отменяются при остановке
+func (s *Server) newHTTPServer(addr string, router *chi.Mux) *http.Server {
+	baseCtx, cancel := context.WithCancel(context.Background())
+	s.cancelRequests = cancel
+
+	return &http.Server{
+		Addr:        addr,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+}
+
 // WaitForShutdown ожидает сигналов завершения работы
 func (s *Server) WaitForShutdown(logger zap.SugaredLogger) error {
 	// Канал для получения сигналов ОС
@@ -71,23 +93,50 @@ func (s *Server) WaitForShutdown(logger zap.SugaredLogger) error {
 	return nil
 }
 
+// Shutdown останавливает HTTP сервер, фоновые воркеры и закрывает хранилище. Воркеры и хранилище останавливаются,
+// даже если HTTP сервер не успел дождаться запросов: иначе пропали бы накопленные переходы и остались открытыми файлы
 func (s *Server) Shutdown() error {
+	timeout := s.shutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
 
 	// Создаем контекст с таймаутом для graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Останавливаем HTTP сервер
-	if err := s.HTTPServer.Shutdown(ctx); err != nil {
-		return err
+	// Останавливаем HTTP сервер. Запросы, не успевшие завершиться, отменяются вместе с их запросами к хранилищу
+	httpErr := s.HTTPServer.Shutdown(ctx)
+	if s.cancelRequests != nil {
+		s.cancelRequests()
 	}
 
-	// Закрываем соединение с базой данных. Close самостоятельно дожидается окончания всех начатых операций с БД
-	if s.DB != nil {
-		if err := s.DB.Close(); err != nil {
-			return err
-		}
+	if s.Blocklist != nil {
+		s.Blocklist.Stop()
 	}
 
-	return nil
+	// Фоновые воркеры останавливаем до закрытия хранилища, иначе они застанут его закрытым
+	if s.Listener != nil {
+		s.Listener.Stop()
+	}
+	if s.Deletions != nil {
+		s.Deletions.Stop() // Дожидается начатых задач, остальные останутся в очереди до следующего запуска
+	}
+	if s.Reaper != nil {
+		s.Reaper.Stop()
+	}
+	if s.Clicks != nil {
+		s.Clicks.Stop() // Сбрасывает в хранилище переходы, накопленные к остановке
+	}
+	if s.Compactor != nil {
+		s.Compactor.Stop()
+	}
+
+	// Закрываем хранилище. Для БД Close самостоятельно дожидается окончания всех начатых операций
+	var closeErr error
+	if s.Repo != nil {
+		closeErr = s.Repo.Close()
+	}
+
+	return errors.Join(httpErr, closeErr)
 }
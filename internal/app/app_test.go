@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/internal/store"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// closeTracker хранилище, запоминающее вызов Close
+type closeTracker struct {
+	store.Repository
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return c.Repository.Close()
+}
+
+// TestShutdownAfterTimeout проверяет, что воркеры и хранилище останавливаются, даже если запросы не успели завершиться
+func TestShutdownAfterTimeout(t *testing.T) {
+	ctx := context.Background()
+	repo := &closeTracker{Repository: store.NewMemoryStore()}
+	shortID, err := repo.Shorten(ctx, "", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	router := chi.NewRouter()
+	router.Get("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+	})
+
+	s := &Server{
+		Repo:            repo,
+		Clicks:          store.StartClickRecorder(repo, *zaptest.NewLogger(t).Sugar()),
+		shutdownTimeout: 50 * time.Millisecond,
+	}
+	s.HTTPServer = s.newHTTPServer("", router)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = s.HTTPServer.Serve(listener) }()
+
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+
+	s.Clicks.Record(models.Visit{ShortURL: shortID, At: time.Now()})
+
+	err = s.Shutdown()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, repo.closed)
+
+	// Переход, накопленный к остановке, сброшен в хранилище
+	stats, err := repo.ClickStats(ctx, shortID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"compress/gzip"
 	"io"
 	"net/http"
 	"strings"
@@ -13,11 +14,54 @@ type gzipWriter struct {
 
 // Write реализация метода Write для gzipWriter с дополнительными проверками Content-Type
 func (g *gzipWriter) Write(b []byte) (int, error) {
-	contentType := g.Header().Get("Content-Type")
 	// Дополнительная проверка здесь, на случай если в одном из middleware хендлеров в дальнейшем будет изменяться contentType
-	if strings.HasPrefix(contentType, "application/json") ||
-		strings.HasPrefix(contentType, "text/html") {
+	if compressible(g.Header().Get("Content-Type")) {
 		return g.Writer.Write(b)
 	}
 	return g.ResponseWriter.Write(b)
 }
+
+// lazyGzipWriter сжимает ответ на запрос без тела (GET). Сжимать или нет, решается по Content-Type ответа,
+// когда хендлер начинает его писать
+type lazyGzipWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	started bool
+}
+
+// WriteHeader включает сжатие, если тип ответа сжимаемый
+func (g *lazyGzipWriter) WriteHeader(status int) {
+	if !g.started {
+		g.started = true
+		if compressible(g.Header().Get("Content-Type")) && status != http.StatusNoContent && status != http.StatusNotModified {
+			g.Header().Set("Content-Encoding", "gzip")
+			g.Header().Del("Content-Length")
+			g.gz = gzip.NewWriter(g.ResponseWriter)
+		}
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+// Write пишет тело, сжимая его, если так решил WriteHeader
+func (g *lazyGzipWriter) Write(b []byte) (int, error) {
+	if !g.started {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// Close дописывает сжатый поток
+func (g *lazyGzipWriter) Close() error {
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	return nil
+}
+
+// compressible сжимаются только JSON и HTML
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/html")
+}
@@ -2,45 +2,97 @@ package app
 
 import (
 	"compress/gzip"
-	"database/sql"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
 	_ "net/http/pprof"
 	"strings"
+	"time"
 
-	"github.com/JohnnyConstantin/urlshort/internal/config"
+	"github.com/JohnnyConstantin/urlshort/internal/blocklist"
 	"github.com/JohnnyConstantin/urlshort/internal/store"
+	"github.com/JohnnyConstantin/urlshort/internal/urlnorm"
 	"github.com/JohnnyConstantin/urlshort/models"
 )
 
 // Handler Объект хендлера
 type Handler struct {
-	router *Router
+	router    *Router
+	repo      store.Repository
+	clicks    *store.ClickRecorder // Учет переходов (опционально)
+	deletions *store.DeletionQueue // Очередь удаления ссылок
+	urls      *urlnorm.Normalizer  // Проверка и нормализация сокращаемых URL
+	blocked   *blocklist.Blocklist // Запрещенные домены (опционально)
+	self      *SelfLinks           // Ссылки на сам сервис (опционально)
+	preview   *Interstitial        // Когда показывать промежуточную страницу вместо редиректа
+
+	blockedStatus int // Ответ на переход по ссылке на запрещенный домен: 451 или 410
 }
 
-// NewHandler Инциализация объекта хендлера с пустым роутером
+// NewHandler Инциализация объекта хендлера с пустым роутером и хранилищем в памяти.
+// URL проверяются по умолчанию: только http и https, без удаления utm_* параметров
 func NewHandler() *Handler {
+	urls, _ := urlnorm.New(nil, false) // Схемы по умолчанию заведомо корректны
 	h := &Handler{
 		router: NewRouter(),
+		repo:   store.NewMemoryStore(),
+		urls:   urls,
+
+		blockedStatus: http.StatusUnavailableForLegalReasons,
 	}
 
 	return h
 }
 
+// SetRepository задает хранилище, выбранное при старте сервера
+func (h *Handler) SetRepository(repo store.Repository) {
+	h.repo = repo
+}
+
+// SetClickRecorder включает учет переходов по ссылкам
+func (h *Handler) SetClickRecorder(clicks *store.ClickRecorder) {
+	h.clicks = clicks
+}
+
+// SetDeletionQueue задает очередь, в которую DELETE /api/user/urls ставит задачи на удаление
+func (h *Handler) SetDeletionQueue(deletions *store.DeletionQueue) {
+	h.deletions = deletions
+}
+
+// SetURLNormalizer задает правила проверки и нормализации сокращаемых URL
+func (h *Handler) SetURLNormalizer(urls *urlnorm.Normalizer) {
+	h.urls = urls
+}
+
+// SetBlocklist включает проверку по списку запрещенных доменов. Уже сохраненные ссылки на них отдают status
+// вместо редиректа
+func (h *Handler) SetBlocklist(blocked *blocklist.Blocklist, status int) {
+	h.blocked = blocked
+	h.blockedStatus = status
+}
+
+// SetSelfLinks включает проверку сокращаемых URL, которые указывают на сам сервис
+func (h *Handler) SetSelfLinks(self *SelfLinks) {
+	h.self = self
+}
+
+// SetInterstitial задает, для каких ссылок показывать промежуточную страницу вместо редиректа.
+// Без настройки она показывается только для ссылок, созданных с interstitial
+func (h *Handler) SetInterstitial(preview *Interstitial) {
+	h.preview = preview
+}
+
 // ServeHTTP Утиная типизация, прокидываемся до функциональной части роутера по роутингу запросов на хендлер
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.router.ServeHTTP(w, r)
 }
 
-// GetHandler обрабатывает GET запросы
+// GetHandler обрабатывает GET запросы: редирект по короткой ссылке, а для /{id}+ - страница предпросмотра
 func (h *Handler) GetHandler(w http.ResponseWriter, r *http.Request) {
-	response := models.ShortenRequest{URL: ""}
-	exists := false //By default не существует
-	var isDeleted bool
-	var status int
 	path := strings.Trim(r.URL.Path, "/")
 	parts := strings.Split(path, "/")
 
@@ -57,50 +109,43 @@ func (h *Handler) GetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id := parts[0]
-
-	cfg := config.GetStorageConfig()
-
-	status = http.StatusTemporaryRedirect //default
+	id, preview := strings.CutSuffix(parts[0], previewSuffix)
 
-	switch cfg.StorageType {
-	case config.StorageFile:
-		fuller := FileFuller{cfg: cfg}
-		fuller.InitMutex()
-		response, exists = fuller.GetFullURL(id)
-	case config.StorageMemory:
-		fuller := MemoryFuller{cfg: cfg}
-		fuller.InitMutex()
-		response, exists = fuller.GetFullURL(id)
-	case config.StorageDB:
-		// Если StorageDB, то в context не может быть nil (на это есть проверка в main), однако, на всякий случай здесь повторяем
-		db, ok := r.Context().Value(dbKey).(*sql.DB)
-		if !ok {
-			sugar.Errorf("Not supported storage type: %v", cfg.StorageType)
-			http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
-			return
-		}
-
-		fuller := DBFuller{db, cfg}
-		response, exists, isDeleted = fuller.GetFullURL(id)
-		if isDeleted {
-			status = http.StatusGone
-		}
-	default:
-		http.Error(w, store.DefaultError, store.DefaultErrorCode)
+	record, err := h.repo.Resolve(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, store.NotFoundError, http.StatusNotFound)
 		return
 	}
-
-	if !exists {
-		http.Error(w, store.DefaultError, store.DefaultErrorCode)
+	if err != nil {
+		sugar.Errorf("Error in resolving short URL %s: %v", id, err)
+		writeStoreError(w, err, store.DefaultErrorCode)
 		return
 	}
 
-	result := response.URL
+	now := time.Now()
+	if record.DeletedFlag || record.Expired(now) {
+		w.Header().Set("Location", record.OriginalURL)
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if rule, blocked := h.blocked.Match(record.OriginalURL); blocked {
+		// Правило могли добавить после сокращения: не редиректим и не учитываем переход
+		sugar.Infow("Redirect to blocked host refused", "id", id, "rule", rule)
+		http.Error(w, store.BlockedError, h.blockedStatus)
+		return
+	}
 
-	w.Header().Set("Location", result)
+	// Предпросмотр - еще не переход, а промежуточная страница заменяет редирект и учитывается как он
+	if !preview && h.clicks != nil {
+		h.clicks.Record(newVisit(r, id, now)) // Не блокирует: запись в хранилище идет в фоне пачками
+	}
+	if preview || h.preview.required(record) {
+		renderPreview(w, sugar, record, !preview)
+		return
+	}
 
-	w.WriteHeader(status)
+	w.Header().Set("Location", record.OriginalURL)
+	w.WriteHeader(http.StatusTemporaryRedirect)
 }
 
 // PostHandler обрабатывает POST запросы
@@ -138,6 +183,7 @@ func (h *Handler) PostHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-Type") == "application/json" {
 		if err = json.Unmarshal(body, &OriginalURL); err != nil {
 			http.Error(w, store.DefaultError, store.DefaultErrorCode)
+			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 	} else {
@@ -145,32 +191,64 @@ func (h *Handler) PostHandler(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 	}
 
-	cfg := config.GetStorageConfig()
-	switch cfg.StorageType {
-	case config.StorageFile:
-		shortener := FileShortener{cfg: cfg}
-		shortener.InitMutex()
-		status = http.StatusCreated
-		ShortURL = shortener.ShortenURL(OriginalURL.URL)
-	case config.StorageMemory:
-		shortener := MemoryShortener{cfg: cfg}
-		shortener.InitMutex()
-		status = http.StatusCreated
-		ShortURL = shortener.ShortenURL(OriginalURL.URL)
-	case config.StorageDB:
-		db, userID, errs := initCtx(r)
-		if errs != nil {
-			sugar.Errorf("Error in initialization of db and userID: %v", errs)
-			http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+	// В хранилище и в Location попадает только проверенный и нормализованный URL
+	OriginalURL.URL, err = h.urls.Normalize(OriginalURL.URL)
+	if err != nil {
+		http.Error(w, err.Error(), store.DefaultErrorCode)
+		return
+	}
+	OriginalURL.URL, err = h.resolveSelfLink(ctx, OriginalURL.URL)
+	if errors.Is(err, errSelfLink) {
+		http.Error(w, err.Error(), store.DefaultErrorCode)
+		return
+	}
+	if err != nil {
+		sugar.Errorf("Error in resolving short link chain: %v", err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
+		return
+	}
+	if _, blocked := h.blocked.Match(OriginalURL.URL); blocked {
+		http.Error(w, store.BlockedError, http.StatusForbidden)
+		return
+	}
+
+	OriginalURL.Title, err = validateTitle(OriginalURL.Title)
+	if err != nil {
+		http.Error(w, err.Error(), store.DefaultErrorCode)
+		return
+	}
+
+	if OriginalURL.Alias != "" {
+		if err = validateAlias(OriginalURL.Alias); err != nil {
+			http.Error(w, err.Error(), store.DefaultErrorCode)
 			return
 		}
-		shortener := DBShortener{db, cfg}
-		ShortURL, status = shortener.ShortenURL(string(userID), OriginalURL.URL)
+	}
+
+	OriginalURL.ExpiresAt, err = resolveExpiry(OriginalURL.ExpiresAt, OriginalURL.TTL, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), store.DefaultErrorCode)
+		return
+	}
+
+	// Хендлер может вызываться без WithAuth (например, в тестах), тогда ссылка сохраняется без владельца
+	userID, _ := ctx.Value(user).(string)
+
+	shortID, err := h.repo.Shorten(ctx, userID, OriginalURL)
+	switch {
+	case err == nil:
+		status = http.StatusCreated
+	case errors.Is(err, store.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, store.ErrAliasTaken):
+		http.Error(w, aliasTakenMessage(OriginalURL.Alias), http.StatusConflict)
+		return
 	default:
-		sugar.Errorf("Unsupported storage type: %v", cfg.StorageType)
-		http.Error(w, store.DefaultError, store.DefaultErrorCode)
+		sugar.Errorf("Error in shortening URL: %v", err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
 		return
 	}
+	ShortURL.Result = buildShortURL(shortID)
 
 	// Перенесенный функционал из JsonMiddleware. Необходимо для применения статус кода и json encoding для app/json Header
 	if r.Header.Get("Content-Type") == "application/json" {
@@ -197,8 +275,6 @@ func (h *Handler) PostHandler(w http.ResponseWriter, r *http.Request) {
 // PostHandlerMultiple обрабатывает POST запросы с batch
 func (h *Handler) PostHandlerMultiple(w http.ResponseWriter, r *http.Request) {
 	var requests []models.BatchShortenRequest
-	var ShortURL models.ShortenResponse
-	var status int
 
 	ctx := r.Context()
 
@@ -230,56 +306,101 @@ func (h *Handler) PostHandlerMultiple(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	responses := make([]models.BatchShortenResponse, 0, len(requests))
-	cfg := config.GetStorageConfig()
-
-	switch cfg.StorageType {
-	case config.StorageFile:
-		shortener := FileShortener{cfg: cfg}
-		shortener.InitMutex()
-		for _, req := range requests {
-			ShortURL = shortener.ShortenURL(req.OriginalURL)
-			status = http.StatusCreated
-
-			responses = append(responses, models.BatchShortenResponse{
-				CorrelationID: req.CorrelationID,
-				ShortURL:      ShortURL.Result,
-			})
+	userID, _ := ctx.Value(user).(string)
+
+	now := time.Now()
+	originals := make([]models.ShortenRequest, 0, len(requests))
+	aliases := make(map[string]bool)
+	for _, req := range requests {
+		originalURL, err := h.urls.Normalize(req.OriginalURL)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("correlation_id %q: %v", req.CorrelationID, err), store.DefaultErrorCode)
+			return
+		}
+		originalURL, err = h.resolveSelfLink(ctx, originalURL)
+		if errors.Is(err, errSelfLink) {
+			http.Error(w, fmt.Sprintf("correlation_id %q: %v", req.CorrelationID, err), store.DefaultErrorCode)
+			return
+		}
+		if err != nil {
+			sugar.Errorf("Error in resolving short link chain: %v", err)
+			writeStoreError(w, err, store.InternalSeverErrorCode)
+			return
+		}
+		if _, blocked := h.blocked.Match(originalURL); blocked {
+			http.Error(w, fmt.Sprintf("correlation_id %q: %s", req.CorrelationID, store.BlockedError), http.StatusForbidden)
+			return
 		}
 
-	case config.StorageMemory:
-		shortener := MemoryShortener{cfg: cfg}
-		shortener.InitMutex()
-		for _, req := range requests {
-			ShortURL = shortener.ShortenURL(req.OriginalURL)
-			status = http.StatusCreated
-
-			responses = append(responses, models.BatchShortenResponse{
-				CorrelationID: req.CorrelationID,
-				ShortURL:      ShortURL.Result,
-			})
+		expiresAt, err := resolveExpiry(req.ExpiresAt, req.TTL, now)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("correlation_id %q: %v", req.CorrelationID, err), store.DefaultErrorCode)
+			return
 		}
-	case config.StorageDB:
-		db, userID, err := initCtx(r)
+
+		title, err := validateTitle(req.Title)
 		if err != nil {
-			sugar.Errorf("Error in initialization of db and userID: %v", err)
-			http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+			http.Error(w, fmt.Sprintf("correlation_id %q: %v", req.CorrelationID, err), store.DefaultErrorCode)
 			return
 		}
-		shortener := DBShortener{db, cfg}
-		for _, req := range requests {
-			ShortURL, status = shortener.ShortenURL(userID, req.OriginalURL)
-
-			responses = append(responses, models.BatchShortenResponse{
-				CorrelationID: req.CorrelationID,
-				ShortURL:      ShortURL.Result,
-			})
+
+		if req.Alias != "" {
+			if err = validateAlias(req.Alias); err != nil {
+				http.Error(w, fmt.Sprintf("correlation_id %q: %v", req.CorrelationID, err), store.DefaultErrorCode)
+				return
+			}
+			if aliases[req.Alias] {
+				http.Error(w, fmt.Sprintf("correlation_id %q: alias %q is used more than once in the batch",
+					req.CorrelationID, req.Alias), store.DefaultErrorCode)
+				return
+			}
+			aliases[req.Alias] = true
+
+			// Проверяем заранее, чтобы назвать клиенту конкретный alias и не сохранять батч частично
+			_, err = h.repo.Resolve(ctx, req.Alias)
+			if err == nil {
+				http.Error(w, aliasTakenMessage(req.Alias), http.StatusConflict)
+				return
+			}
+			if !errors.Is(err, store.ErrNotFound) {
+				sugar.Errorf("Error in resolving alias %s: %v", req.Alias, err)
+				writeStoreError(w, err, store.InternalSeverErrorCode)
+				return
+			}
 		}
-	default: // Overkill, но перестраховаться нужно
-		sugar.Errorf("Unsupported storage type: %v", cfg.StorageType)
-		http.Error(w, store.DefaultError, store.DefaultErrorCode)
+		originals = append(originals, models.ShortenRequest{
+			URL:          originalURL,
+			Alias:        req.Alias,
+			ExpiresAt:    expiresAt,
+			Title:        title,
+			Interstitial: req.Interstitial,
+		})
+	}
+
+	results, err := h.repo.ShortenBatch(ctx, userID, originals)
+	if errors.Is(err, store.ErrAliasTaken) { // alias заняли между проверкой и вставкой
+		http.Error(w, store.ErrAliasTaken.Error(), http.StatusConflict)
 		return
 	}
+	if err != nil {
+		sugar.Errorf("Error in shortening batch: %v", err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
+		return
+	}
+
+	// Как и одиночный хендлер, отвечаем 409, если хотя бы один URL уже был сокращен. Какие именно - видно по полю conflict
+	status := http.StatusCreated
+	responses := make([]models.BatchShortenResponse, 0, len(requests))
+	for i, req := range requests {
+		if results[i].Conflict {
+			status = http.StatusConflict
+		}
+		responses = append(responses, models.BatchShortenResponse{
+			CorrelationID: req.CorrelationID,
+			ShortURL:      buildShortURL(results[i].ShortID),
+			Conflict:      results[i].Conflict,
+		})
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -289,9 +410,9 @@ func (h *Handler) PostHandlerMultiple(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// DeleteHandlerMultiple удаляет несколько записей ссылок
+// DeleteHandlerMultiple ставит в очередь удаление нескольких ссылок и сразу отвечает идентификатором задачи
 func (h *Handler) DeleteHandlerMultiple(w http.ResponseWriter, r *http.Request) {
-	db, userID, err := initCtx(r)
+	userID, err := userFromCtx(r)
 	if err != nil {
 		http.Error(w, err.Error(), store.InternalSeverErrorCode)
 		return
@@ -305,8 +426,6 @@ func (h *Handler) DeleteHandlerMultiple(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	deleter := DBDeleter{cfg: config.GetStorageConfig(), db: db}
-
 	// Парсим тело запроса
 	var shortURLs []string
 	if err := json.NewDecoder(r.Body).Decode(&shortURLs); err != nil {
@@ -315,17 +434,83 @@ func (h *Handler) DeleteHandlerMultiple(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := deleter.DeleteURL(userID, shortURLs); err != nil {
-		sugar.Errorf("Error in deleting URL: %v", err)
+	if h.deletions == nil {
+		sugar.Error("Deletion queue is not configured")
 		http.Error(w, store.DefaultError, http.StatusInternalServerError)
+		return
 	}
 
+	// Удаление выполнит воркер. Задача уже сохранена в хранилище, поэтому переживет перезапуск
+	job, err := h.deletions.Enqueue(ctx, userID, shortURLs)
+	if err != nil {
+		sugar.Errorf("Error in enqueueing deletion: %v", err)
+		writeStoreError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/user/jobs/"+job.ID)
 	w.WriteHeader(http.StatusAccepted)
+	if err = json.NewEncoder(w).Encode(models.DeleteResponse{JobID: job.ID}); err != nil {
+		sugar.Errorf("Error in encoding response body: %v", err)
+	}
+}
+
+// GetJobHandler отдает владельцу статус задачи на удаление: GET /api/user/jobs/{id}
+func (h *Handler) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := userFromCtx(r)
+	if err != nil {
+		http.Error(w, err.Error(), store.InternalSeverErrorCode)
+		return
+	}
+
+	ctx := r.Context()
+
+	sugar, ok := ctx.Value(loggerKey).(zap.SugaredLogger)
+	if !ok {
+		http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] != "jobs" {
+		http.Error(w, store.BadRequestError, store.DefaultErrorCode)
+		return
+	}
+	id := parts[3]
+
+	// Как и для ссылок, чужая задача неотличима от несуществующей
+	job, err := h.repo.DeletionJob(ctx, id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && job.UserID != userID) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sugar.Errorf("Error in reading deletion job %s: %v", id, err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(models.JobResponse{
+		ID:        job.ID,
+		Status:    job.Status,
+		Deleted:   job.Deleted,
+		NotFound:  job.NotFound,
+		NotOwned:  job.NotOwned,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	})
+	if err != nil {
+		sugar.Errorf("Error in encoding response body: %v", err)
+		http.Error(w, "JSON encoding failed", http.StatusInternalServerError)
+	}
 }
 
 // GetHandlerMultiple получить несколько полных URL по сокращенным
 func (h *Handler) GetHandlerMultiple(w http.ResponseWriter, r *http.Request) {
-	db, userID, err := initCtx(r)
+	userID, err := userFromCtx(r)
 	if err != nil {
 		http.Error(w, err.Error(), store.InternalSeverErrorCode)
 		return
@@ -339,9 +524,10 @@ func (h *Handler) GetHandlerMultiple(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	urls, err := store.ReadWithUUID(db, userID)
+	urls, err := h.repo.ListByUser(ctx, userID)
 	if err != nil {
-		http.Error(w, store.DefaultError, store.DefaultErrorCode)
+		sugar.Errorf("Error in listing user URLs: %v", err)
+		writeStoreError(w, err, store.DefaultErrorCode)
 		return
 	}
 
@@ -360,6 +546,70 @@ func (h *Handler) GetHandlerMultiple(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusTemporaryRedirect)
 }
 
+// GetStatsHandler отдает владельцу статистику переходов по ссылке: GET /api/user/urls/{id}/stats
+func (h *Handler) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveOwnedLinkReport(w, r, "stats", func(ctx context.Context, id string) (any, error) {
+		return h.repo.ClickStats(ctx, id)
+	})
+}
+
+// GetVisitsHandler отдает владельцу разбивку посещений ссылки: GET /api/user/urls/{id}/visits
+func (h *Handler) GetVisitsHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveOwnedLinkReport(w, r, "visits", func(ctx context.Context, id string) (any, error) {
+		return h.repo.VisitStats(ctx, id)
+	})
+}
+
+// serveOwnedLinkReport общая часть отчетов по ссылке /api/user/urls/{id}/{report}: проверка владельца и JSON ответ
+func (h *Handler) serveOwnedLinkReport(w http.ResponseWriter, r *http.Request, report string,
+	build func(ctx context.Context, id string) (any, error)) {
+	userID, err := userFromCtx(r)
+	if err != nil {
+		http.Error(w, err.Error(), store.InternalSeverErrorCode)
+		return
+	}
+
+	ctx := r.Context()
+
+	sugar, ok := ctx.Value(loggerKey).(zap.SugaredLogger)
+	if !ok {
+		http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 5 || parts[4] != report {
+		http.Error(w, store.BadRequestError, store.DefaultErrorCode)
+		return
+	}
+	id := parts[3]
+
+	// Чужая ссылка неотличима от несуществующей, чтобы не раскрывать чужие идентификаторы
+	record, err := h.repo.Resolve(ctx, id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && record.UUID != userID) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sugar.Errorf("Error in resolving short URL %s: %v", id, err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
+		return
+	}
+
+	result, err := build(ctx, id)
+	if err != nil {
+		sugar.Errorf("Error in building %s for %s: %v", report, id, err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(result); err != nil {
+		sugar.Errorf("Error in encoding response body: %v", err)
+		http.Error(w, "JSON encoding failed", http.StatusInternalServerError)
+	}
+}
+
 // GzipHandle мидлварь для работы со сжатием
 func GzipHandle(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -384,8 +634,7 @@ func GzipHandle(next http.HandlerFunc) http.HandlerFunc {
 
 		if acceptsGzip { // Если клиент поддерживает сжатие, проверяем передаваемый content-type
 			contentType := r.Header.Get("Content-Type")
-			if strings.HasPrefix(contentType, "application/json") ||
-				strings.HasPrefix(contentType, "text/html") {
+			if compressible(contentType) {
 
 				gzWriter := gzip.NewWriter(w) // Жмем!
 				defer func(gzWriter *gzip.Writer) {
@@ -400,6 +649,13 @@ func GzipHandle(next http.HandlerFunc) http.HandlerFunc {
 					ResponseWriter: w,
 					Writer:         gzWriter,
 				}
+			} else if contentType == "" {
+				// У GET запросов нет тела и Content-Type, тогда сжатие выбирается по типу ответа (например, HTML страницы)
+				lazy := &lazyGzipWriter{ResponseWriter: w}
+				defer func(lazy *lazyGzipWriter) {
+					_ = lazy.Close()
+				}(lazy)
+				originalWriter = lazy
 			}
 		}
 
@@ -409,30 +665,33 @@ func GzipHandle(next http.HandlerFunc) http.HandlerFunc {
 
 // PingDBHandler Проверяет подключение к БД
 func (h *Handler) PingDBHandler(w http.ResponseWriter, r *http.Request) {
-	cfg := config.GetStorageConfig()
-	if cfg.StorageType == config.StorageDB {
-		db, ok := r.Context().Value(dbKey).(*sql.DB)
-		if !ok {
-			http.Error(w, store.ConnectionError, http.StatusInternalServerError)
-		}
-		err := db.Ping()
-		if err != nil {
-			http.Error(w, store.ConnectionError, store.InternalSeverErrorCode)
-		}
+	if err := h.repo.Ping(r.Context()); err != nil {
+		http.Error(w, store.ConnectionError, store.InternalSeverErrorCode)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func initCtx(r *http.Request) (*sql.DB, string, error) {
-	db, ok := r.Context().Value(dbKey).(*sql.DB)
-	if !ok {
-		return nil, "", errors.New("DB not in context")
+// writeStoreError отвечает на ошибку хранилища. Таймаут запроса к хранилищу - 504, недоступное после повторов
+// хранилище или отмененный запрос (клиент отключился, сервер останавливается) - 503, остальные ошибки - fallback
+// с текстом по умолчанию
+func writeStoreError(w http.ResponseWriter, err error, fallback int) {
+	switch {
+	case errors.Is(err, store.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, store.TimeoutError, http.StatusGatewayTimeout)
+	case errors.Is(err, store.ErrUnavailable), errors.Is(err, context.Canceled):
+		http.Error(w, store.UnavailableError, http.StatusServiceUnavailable)
+	default:
+		http.Error(w, store.DefaultError, fallback)
 	}
+}
+
+func userFromCtx(r *http.Request) (string, error) {
 	userID, ok := r.Context().Value(user).(string)
 	if !ok {
-		return nil, "", errors.New("userID not found in context")
+		return "", errors.New("userID not found in context")
 	}
 
-	return db, userID, nil
+	return userID, nil
 }
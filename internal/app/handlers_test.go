@@ -6,15 +6,20 @@ import (
 	"context"
 	"encoding/json"
 	"go.uber.org/zap"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/JohnnyConstantin/urlshort/internal/blocklist"
 	"github.com/JohnnyConstantin/urlshort/internal/config"
 	"github.com/JohnnyConstantin/urlshort/internal/store"
 	"github.com/JohnnyConstantin/urlshort/models"
@@ -130,6 +135,295 @@ func TestGetHandler(t *testing.T) {
 	assert.Equalf(t, testURL, location, "expected Location header %s, got '%s'", testURL, location)
 }
 
+// TestUserURLsLifecycle проверяет список ссылок пользователя и мягкое удаление для хранилищ в памяти и в файле
+func TestUserURLsLifecycle(t *testing.T) {
+	loggers, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	sugar := *loggers.Sugar()
+
+	fileStore, err := store.OpenFileStore(filepath.Join(t.TempDir(), "urls.jsonl"), sugar)
+	require.NoError(t, err)
+
+	repos := map[string]store.Repository{
+		"memory": store.NewMemoryStore(),
+		"file":   fileStore,
+	}
+
+	ids := make(map[string]string, len(repos))
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			handler := NewHandler()
+			handler.SetRepository(repo)
+			deletions := store.StartDeletionQueue(repo, 1, sugar)
+			defer deletions.Stop()
+			handler.SetDeletionQueue(deletions)
+
+			ownerCtx := context.WithValue(context.WithValue(context.Background(), loggerKey, sugar), user, "owner")
+			strangerCtx := context.WithValue(context.WithValue(context.Background(), loggerKey, sugar), user, "stranger")
+
+			shorten := func(url string) string {
+				rr := httptest.NewRecorder()
+				handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url)).WithContext(ownerCtx))
+				require.Equal(t, http.StatusCreated, rr.Code)
+				return strings.TrimPrefix(rr.Body.String(), config.Options.BaseAddress+"/")
+			}
+			status := func(id string) int {
+				rr := httptest.NewRecorder()
+				handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ownerCtx))
+				return rr.Code
+			}
+			deleteURLs := func(ctx context.Context, body string) string {
+				rr := httptest.NewRecorder()
+				handler.DeleteHandlerMultiple(rr, httptest.NewRequest(http.MethodDelete, "/api/user/urls",
+					strings.NewReader(body)).WithContext(ctx))
+				require.Equal(t, http.StatusAccepted, rr.Code)
+				var resp models.DeleteResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.NotEmpty(t, resp.JobID)
+				assert.Equal(t, "/api/user/jobs/"+resp.JobID, rr.Header().Get("Location"))
+				return resp.JobID
+			}
+			getJob := func(ctx context.Context, jobID string) (int, models.JobResponse) {
+				rr := httptest.NewRecorder()
+				handler.GetJobHandler(rr, httptest.NewRequest(http.MethodGet, "/api/user/jobs/"+jobID, nil).WithContext(ctx))
+				var job models.JobResponse
+				if rr.Code == http.StatusOK {
+					require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+				}
+				return rr.Code, job
+			}
+			waitJob := func(ctx context.Context, jobID string) models.JobResponse {
+				var job models.JobResponse
+				require.Eventually(t, func() bool {
+					var code int
+					code, job = getJob(ctx, jobID)
+					return code == http.StatusOK && job.Status == models.JobDone
+				}, time.Second, 10*time.Millisecond)
+				return job
+			}
+
+			id := shorten("https://example.com/owned")
+			ids[name] = id
+
+			// Ссылка видна только владельцу
+			rr := httptest.NewRecorder()
+			handler.GetHandlerMultiple(rr, httptest.NewRequest(http.MethodGet, "/api/user/urls", nil).WithContext(ownerCtx))
+			var urls []models.URLResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+			require.Len(t, urls, 1)
+			assert.Equal(t, "https://example.com/owned", urls[0].OriginalURL)
+
+			rr = httptest.NewRecorder()
+			handler.GetHandlerMultiple(rr, httptest.NewRequest(http.MethodGet, "/api/user/urls", nil).WithContext(strangerCtx))
+			assert.Equal(t, http.StatusNoContent, rr.Code)
+
+			// Чужой пользователь не может удалить ссылку, итог задачи это показывает
+			jobID := deleteURLs(strangerCtx, `["`+id+`", "missing"]`)
+			job := waitJob(strangerCtx, jobID)
+			assert.Equal(t, 0, job.Deleted)
+			assert.Equal(t, 1, job.NotFound)
+			assert.Equal(t, 1, job.NotOwned)
+			assert.Equal(t, http.StatusTemporaryRedirect, status(id))
+
+			// Владелец удаляет ссылку, после чего она отдает 410. Чужую задачу он не видит
+			jobID = deleteURLs(ownerCtx, `["`+id+`"]`)
+			code, _ := getJob(strangerCtx, jobID)
+			assert.Equal(t, http.StatusNotFound, code)
+			job = waitJob(ownerCtx, jobID)
+			assert.Equal(t, 1, job.Deleted)
+			assert.Equal(t, http.StatusGone, status(id))
+		})
+	}
+
+	// Владелец и удаление переживают перезапуск файлового хранилища
+	reopened, err := store.OpenFileStore(fileStore.Path(), sugar)
+	require.NoError(t, err)
+	record, err := reopened.Resolve(context.Background(), ids["file"])
+	require.NoError(t, err)
+	assert.Equal(t, "owner", record.UUID)
+	assert.True(t, record.DeletedFlag)
+}
+
+// TestPostHandlerAlias проверяет сокращение с пользовательским alias
+func TestPostHandlerAlias(t *testing.T) {
+	loggers, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), loggerKey, *loggers.Sugar())
+
+	handler := NewHandler()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Free alias",
+			body:       `{"url":"https://example.com/spring","alias":"spring-sale"}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   "/spring-sale",
+		},
+		{
+			name:       "Taken alias",
+			body:       `{"url":"https://example.com/other","alias":"spring-sale"}`,
+			wantStatus: http.StatusConflict,
+			wantBody:   "already taken",
+		},
+		{
+			name:       "Reserved alias",
+			body:       `{"url":"https://example.com/other","alias":"API"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "reserved",
+		},
+		{
+			name:       "Invalid characters",
+			body:       `{"url":"https://example.com/other","alias":"spring/sale"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "only latin letters",
+		},
+		{
+			name:       "Too short",
+			body:       `{"url":"https://example.com/other","alias":"ab"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "length",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body)).WithContext(ctx)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			handler.PostHandler(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+
+	// Батч с занятым alias отклоняется целиком
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(
+		`[{"correlation_id":"1","original_url":"https://example.com/a","alias":"summer-sale"},`+
+			`{"correlation_id":"2","original_url":"https://example.com/b","alias":"spring-sale"}]`)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler.PostHandlerMultiple(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	_, err = handler.repo.Resolve(ctx, "summer-sale")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// Ошибки проверки alias в батче называют элемент
+	for body, want := range map[string]string{
+		`[{"correlation_id":"1","original_url":"https://example.com/a","alias":"ab"}]`: `correlation_id "1":`,
+		`[{"correlation_id":"1","original_url":"https://example.com/a","alias":"autumn-sale"},` +
+			`{"correlation_id":"2","original_url":"https://example.com/b","alias":"autumn-sale"}]`: `correlation_id "2":`,
+	} {
+		rr = httptest.NewRecorder()
+		handler.PostHandlerMultiple(rr, httptest.NewRequest(http.MethodPost, "/api/shorten/batch",
+			strings.NewReader(body)).WithContext(ctx))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), want)
+	}
+}
+
+// TestPostHandlerExpiry проверяет валидацию срока жизни и 410 для истекшей ссылки
+func TestPostHandlerExpiry(t *testing.T) {
+	loggers, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), loggerKey, *loggers.Sugar())
+
+	handler := NewHandler()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "TTL", body: `{"url":"https://example.com/ttl","ttl":3600}`, wantStatus: http.StatusCreated},
+		{name: "Negative TTL", body: `{"url":"https://example.com/negative","ttl":-1}`, wantStatus: http.StatusBadRequest},
+		{name: "Past expires_at", body: `{"url":"https://example.com/past","expires_at":"2000-01-01T00:00:00Z"}`, wantStatus: http.StatusBadRequest},
+		{
+			name:       "Both expires_at and TTL",
+			body:       `{"url":"https://example.com/both","ttl":60,"expires_at":"2999-01-01T00:00:00Z"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body)).WithContext(ctx)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			handler.PostHandler(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+
+	// В батче ошибка срока жизни называет элемент
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(
+		`[{"correlation_id":"1","original_url":"https://example.com/ok"},`+
+			`{"correlation_id":"2","original_url":"https://example.com/bad","ttl":-1}]`)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler.PostHandlerMultiple(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `correlation_id "2":`)
+
+	// Истекшая, но еще не убранная ссылка отдает 410
+	past := time.Now().Add(-time.Second)
+	id, err := handler.repo.Shorten(ctx, "", models.ShortenRequest{URL: "https://example.com/expired", ExpiresAt: &past})
+	require.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ctx))
+	assert.Equal(t, http.StatusGone, rr.Code)
+}
+
+// TestGetStatsHandler проверяет учет переходов и доступ к статистике только для владельца
+func TestGetStatsHandler(t *testing.T) {
+	loggers, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	sugar := *loggers.Sugar()
+
+	repo := store.NewMemoryStore()
+	handler := NewHandler()
+	handler.SetRepository(repo)
+	clicks := store.StartClickRecorder(repo, sugar)
+	handler.SetClickRecorder(clicks)
+
+	ownerCtx := context.WithValue(context.WithValue(context.Background(), loggerKey, sugar), user, "owner")
+	strangerCtx := context.WithValue(context.WithValue(context.Background(), loggerKey, sugar), user, "stranger")
+
+	id, err := repo.Shorten(ownerCtx, "owner", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ownerCtx))
+		require.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+	}
+	clicks.Stop()
+
+	rr := httptest.NewRecorder()
+	handler.GetStatsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/user/urls/"+id+"/stats", nil).WithContext(ownerCtx))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var stats models.LinkStats
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Len(t, stats.Daily, 1)
+
+	rr = httptest.NewRecorder()
+	handler.GetStatsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/user/urls/"+id+"/stats", nil).WithContext(strangerCtx))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.GetStatsHandler(rr, httptest.NewRequest(http.MethodGet, "/api/user/urls/missing/stats", nil).WithContext(ownerCtx))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
 func BenchmarkGetHandler(t *testing.B) {
 	t.StopTimer() // останавливаем таймер
 	config.CreateStorageConfig()
@@ -221,7 +515,7 @@ func TestPostHandlerMultiple(t *testing.T) {
 				{"correlation_id": "2"}
 			]`,
 			storageType:    config.StorageMemory,
-			expectedStatus: http.StatusCreated,
+			expectedStatus: store.DefaultErrorCode,
 			wantError:      true,
 		},
 	}
@@ -291,6 +585,48 @@ func TestPostHandlerMultiple(t *testing.T) {
 	}
 }
 
+// TestPostHandlerMultipleConflict проверяет, что батч сообщает, какие URL уже были сокращены
+func TestPostHandlerMultipleConflict(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	// Повторный оригинал распознают хранилища с дедупликацией
+	repo, err := store.OpenSQLite(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+	handler.SetRepository(repo)
+
+	batch := func(body string) (int, []models.BatchShortenResponse) {
+		rr := httptest.NewRecorder()
+		handler.PostHandlerMultiple(rr, httptest.NewRequest(http.MethodPost, "/api/shorten/batch",
+			strings.NewReader(body)).WithContext(ctx))
+		var responses []models.BatchShortenResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &responses), rr.Body.String())
+		return rr.Code, responses
+	}
+
+	status, first := batch(`[{"correlation_id": "1", "original_url": "https://example.com/1"}]`)
+	require.Equal(t, http.StatusCreated, status)
+	assert.False(t, first[0].Conflict)
+
+	status, responses := batch(`[
+		{"correlation_id": "1", "original_url": "https://example.com/1"},
+		{"correlation_id": "2", "original_url": "https://example.com/2"}
+	]`)
+	assert.Equal(t, http.StatusConflict, status)
+	require.Len(t, responses, 2)
+	assert.True(t, responses[0].Conflict)
+	assert.Equal(t, first[0].ShortURL, responses[0].ShortURL)
+	assert.False(t, responses[1].Conflict)
+
+	// Alias к уже сокращенному URL не применяется, и об этом сообщается так же, как об обычном конфликте
+	status, responses = batch(`[{"correlation_id": "1", "original_url": "https://example.com/1", "alias": "my-link"}]`)
+	assert.Equal(t, http.StatusConflict, status)
+	require.Len(t, responses, 1)
+	assert.True(t, responses[0].Conflict)
+	assert.Equal(t, first[0].ShortURL, responses[0].ShortURL)
+}
+
 // Вспомогательная функция для создания gzip сжатых данных
 func gzipData(data string) ([]byte, error) {
 	var buf bytes.Buffer
@@ -524,3 +860,378 @@ func BenchmarkPostHandlerMultiple(t *testing.B) {
 	req = req.WithContext(ctx)
 	handler.PostHandlerMultiple(rr, req)
 }
+
+// slowRepo не отвечает на Resolve до отмены контекста
+type slowRepo struct {
+	store.Repository
+}
+
+func (r *slowRepo) Resolve(ctx context.Context, _ string) (models.URLRecord, error) {
+	<-ctx.Done()
+	return models.URLRecord{}, ctx.Err()
+}
+
+// TestStoreTimeoutStatus проверяет, что таймаут хранилища отдается как 504, а отмененный запрос как 503, а не 400
+func TestStoreTimeoutStatus(t *testing.T) {
+	handler := NewHandler()
+	handler.SetRepository(store.NewTimeoutRepository(&slowRepo{Repository: store.NewMemoryStore()},
+		store.Timeouts{Read: 10 * time.Millisecond}))
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	rr := httptest.NewRecorder()
+	handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/abc", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	rr = httptest.NewRecorder()
+	handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/abc", nil).WithContext(canceled))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+// TestPostHandlerURLValidation проверяет отказ 400 с причиной для некорректных URL и сохранение нормализованного URL
+func TestPostHandlerURLValidation(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	for _, body := range []string{"", "not a url", "javascript:alert(1)", "data:text/html,hi", "http://"} {
+		rr := httptest.NewRecorder()
+		handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)).WithContext(ctx))
+		assert.Equal(t, store.DefaultErrorCode, rr.Code, body)
+		assert.Contains(t, rr.Body.String(), "invalid url", body)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.PostHandlerMultiple(rr, httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(
+		`[{"correlation_id": "ok", "original_url": "https://example.com"}, {"correlation_id": "bad", "original_url": "ftp://example.com"}]`,
+	)).WithContext(ctx))
+	assert.Equal(t, store.DefaultErrorCode, rr.Code)
+	assert.Contains(t, rr.Body.String(), `correlation_id "bad"`)
+	assert.Contains(t, rr.Body.String(), `scheme "ftp" is not allowed`)
+
+	// Разное написание одного адреса сокращается в одну ссылку. Повторный оригинал распознают хранилища с дедупликацией
+	repo, err := store.OpenSQLite(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer repo.Close()
+	handler.SetRepository(repo)
+
+	rr = httptest.NewRecorder()
+	handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("HTTPS://Normalized.Example:443/a")).WithContext(ctx))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	id := strings.TrimPrefix(rr.Body.String(), config.Options.BaseAddress+"/")
+
+	rr = httptest.NewRecorder()
+	handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://normalized.example/a")).WithContext(ctx))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ctx))
+	assert.Equal(t, "https://normalized.example/a", rr.Header().Get("Location"))
+}
+
+// TestBlockedHosts проверяет, что запрещенные домены не сокращаются, а сохраненные ранее ссылки на них не редиректят
+func TestBlockedHosts(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	rr := httptest.NewRecorder()
+	handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://login.evil.example/")).WithContext(ctx))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	id := strings.TrimPrefix(rr.Body.String(), config.Options.BaseAddress+"/")
+
+	// Правило появилось после сокращения
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("*.evil.example\n"), 0o600))
+	blocked, err := blocklist.Start(path, time.Hour, *zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer blocked.Stop()
+	handler.SetBlocklist(blocked, http.StatusUnavailableForLegalReasons)
+
+	rr = httptest.NewRecorder()
+	handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ctx))
+	assert.Equal(t, http.StatusUnavailableForLegalReasons, rr.Code)
+	assert.Empty(t, rr.Header().Get("Location"))
+
+	handler.SetBlocklist(blocked, http.StatusGone)
+	rr = httptest.NewRecorder()
+	handler.GetHandler(rr, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ctx))
+	assert.Equal(t, http.StatusGone, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://Other.EVIL.example:443/")).WithContext(ctx))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.PostHandlerMultiple(rr, httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(
+		`[{"correlation_id": "ok", "original_url": "https://example.com"}, {"correlation_id": "bad", "original_url": "https://a.evil.example"}]`,
+	)).WithContext(ctx))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), `correlation_id "bad"`)
+
+	rr = httptest.NewRecorder()
+	handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://evil.example/")).WithContext(ctx))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
+
+// TestPostHandlerSelfLinks проверяет, что ссылки на сам сервис раскрываются до конечного URL или отклоняются
+func TestPostHandlerSelfLinks(t *testing.T) {
+	handler := NewHandler()
+	repo := store.NewMemoryStore()
+	handler.SetRepository(repo)
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	// Цепочки и петля, сохраненные в обход проверки
+	for alias, target := range map[string]string{
+		"one":   "http://short.example/two",
+		"two":   "https://sho.rt:8443/l/three",
+		"three": "https://final.example/page",
+		"loopa": "http://short.example/loopb",
+		"loopb": "http://short.example/loopa",
+	} {
+		_, err := repo.Shorten(context.Background(), "", models.ShortenRequest{URL: target, Alias: alias})
+		require.NoError(t, err)
+	}
+
+	self, err := NewSelfLinks([]string{"http://Short.Example", "https://sho.rt:8443/l/"}, SelfLinksResolve, 3)
+	require.NoError(t, err)
+	handler.SetSelfLinks(self)
+
+	shorten := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(target)).WithContext(ctx))
+		return rr
+	}
+	location := func(rr *httptest.ResponseRecorder) string {
+		id := strings.TrimPrefix(rr.Body.String(), config.Options.BaseAddress+"/")
+		get := httptest.NewRecorder()
+		handler.GetHandler(get, httptest.NewRequest(http.MethodGet, "/"+id, nil).WithContext(ctx))
+		return get.Header().Get("Location")
+	}
+
+	rr := shorten("https://SHORT.example/one?utm_source=x")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "https://final.example/page", location(rr))
+
+	rr = shorten("https://sho.rt:8443/l/two")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "https://final.example/page", location(rr))
+
+	// Похожие, но чужие адреса не трогаются
+	for _, target := range []string{"https://short.example.com/one", "https://sho.rt/l/one", "https://sho.rt:8443/list/one"} {
+		assert.Equal(t, http.StatusCreated, shorten(target).Code, target)
+	}
+
+	tests := []struct {
+		target string
+		reason string
+	}{
+		{target: "http://short.example/loopa", reason: "loop"},
+		{target: "http://short.example/missing", reason: "does not exist"},
+		{target: "http://short.example/", reason: "points to this shortener"},
+		{target: "http://short.example/api/shorten", reason: "points to this shortener"},
+	}
+	for _, tt := range tests {
+		rr = shorten(tt.target)
+		assert.Equal(t, store.DefaultErrorCode, rr.Code, tt.target)
+		assert.Contains(t, rr.Body.String(), tt.reason, tt.target)
+	}
+
+	// Глубина цепочки ограничена
+	self, err = NewSelfLinks([]string{"http://short.example", "https://sho.rt:8443/l"}, SelfLinksResolve, 2)
+	require.NoError(t, err)
+	handler.SetSelfLinks(self)
+	rr = shorten("http://short.example/one")
+	assert.Equal(t, store.DefaultErrorCode, rr.Code)
+	assert.Contains(t, rr.Body.String(), "longer than 2")
+
+	self, err = NewSelfLinks([]string{"http://short.example"}, SelfLinksReject, 3)
+	require.NoError(t, err)
+	handler.SetSelfLinks(self)
+	rr = httptest.NewRecorder()
+	handler.PostHandlerMultiple(rr, httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(
+		`[{"correlation_id": "ok", "original_url": "https://example.com"}, {"correlation_id": "self", "original_url": "http://short.example/three"}]`,
+	)).WithContext(ctx))
+	assert.Equal(t, store.DefaultErrorCode, rr.Code)
+	assert.Contains(t, rr.Body.String(), `correlation_id "self": url points to this shortener`)
+
+	_, err = NewSelfLinks([]string{"http://short.example"}, "follow", 3)
+	assert.Error(t, err)
+}
+
+// TestPreviewPage проверяет страницу предпросмотра /{id}+ и промежуточную страницу вместо редиректа
+func TestPreviewPage(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	shorten := func(body string) string {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.PostHandler(rr, req)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+		var resp models.ShortenResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return strings.TrimPrefix(resp.Result, config.Options.BaseAddress+"/")
+	}
+	get := func(path string, gzipped bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		rr := httptest.NewRecorder()
+		GzipHandle(handler.GetHandler)(rr, req)
+		return rr
+	}
+
+	docs := shorten(`{"url": "https://docs.example/guide?a=1&b=2", "title": "  <b>Guide</b>  "}`)
+	plain := shorten(`{"url": "https://plain.example/"}`)
+	warned := shorten(`{"url": "https://warned.example/", "interstitial": true}`)
+
+	rr := get("/"+docs+"+", false)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Empty(t, rr.Header().Get("Location"))
+	page := rr.Body.String()
+	assert.Contains(t, page, "&lt;b&gt;Guide&lt;/b&gt;")
+	assert.NotContains(t, page, "<b>Guide")
+	assert.Contains(t, page, `href="https://docs.example/guide?a=1&amp;b=2"`)
+	assert.Contains(t, page, "<dd>docs.example</dd>")
+	assert.Contains(t, page, "<dt>Created</dt>")
+	assert.NotContains(t, page, "You are leaving")
+
+	// Страница идет через GzipHandle и сжимается, как и JSON
+	rr = get("/"+plain+"+", true)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "<h1>plain.example</h1>")
+
+	rr = get("/"+plain, true)
+	assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+
+	// Промежуточная страница по флагу ссылки
+	rr = get("/"+warned, false)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "You are leaving")
+
+	// И для всех внешних доменов, кроме доверенных
+	interstitial, err := NewInterstitial(true, []string{"Docs.Example"})
+	require.NoError(t, err)
+	handler.SetInterstitial(interstitial)
+	assert.Equal(t, http.StatusTemporaryRedirect, get("/"+docs, false).Code)
+	assert.Equal(t, http.StatusOK, get("/"+plain, false).Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(
+		`{"url": "https://long.example/", "title": "`+strings.Repeat("я", maxTitleLength+1)+`"}`,
+	)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	handler.PostHandler(rr, req)
+	assert.Equal(t, store.DefaultErrorCode, rr.Code)
+	assert.Contains(t, rr.Body.String(), "title must be at most")
+}
+
+// TestGetQRHandler проверяет QR-код короткой ссылки, параметры, заголовки кэширования и ответы для отсутствующих ссылок
+func TestGetQRHandler(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	rr := httptest.NewRecorder()
+	handler.PostHandler(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://print.example/")).WithContext(ctx))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	id := strings.TrimPrefix(rr.Body.String(), config.Options.BaseAddress+"/")
+
+	qr := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		handler.GetQRHandler(rr, req)
+		return rr
+	}
+
+	rr = qr("/"+id+"/qr?size=300&ecc=h&margin=2", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Cache-Control"), "no-cache")
+	img, err := png.Decode(rr.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, qr("/"+id+"/qr?size=300&ecc=H&margin=2", http.Header{"If-None-Match": {etag}}).Code)
+	assert.NotEqual(t, etag, qr("/"+id+"/qr?size=300&ecc=L&margin=2", nil).Header().Get("ETag"))
+
+	rr = qr("/"+id+"/qr?format=svg&size=512", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `width="512" height="512"`)
+
+	for _, query := range []string{"format=gif", "size=10", "size=big", "ecc=Z", "margin=-1", "margin=100"} {
+		assert.Equal(t, store.DefaultErrorCode, qr("/"+id+"/qr?"+query, nil).Code, query)
+	}
+
+	assert.Equal(t, http.StatusNotFound, qr("/missing1/qr", nil).Code)
+
+	// Закэшированный код перепроверяется: после удаления ссылки вместо 304 приходит 410
+	_, err = handler.repo.Delete(context.Background(), "", []string{id})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGone, qr("/"+id+"/qr?size=300&ecc=H&margin=2", http.Header{"If-None-Match": {etag}}).Code)
+}
+
+// TestLinkStatusConsistency проверяет, что редирект и QR-код одинаково отвечают на неизвестные, удаленные,
+// истекшие и заблокированные ссылки
+func TestLinkStatusConsistency(t *testing.T) {
+	handler := NewHandler()
+	ctx := context.WithValue(context.Background(), loggerKey, *zap.NewNop().Sugar())
+
+	shorten := func(req models.ShortenRequest) string {
+		id, err := handler.repo.Shorten(context.Background(), "owner", req)
+		require.NoError(t, err)
+		return id
+	}
+	past := time.Now().Add(-time.Minute)
+	deleted := shorten(models.ShortenRequest{URL: "https://deleted.example/"})
+	_, err := handler.repo.Delete(context.Background(), "owner", []string{deleted})
+	require.NoError(t, err)
+	expired := shorten(models.ShortenRequest{URL: "https://expired.example/", ExpiresAt: &past})
+	blocked := shorten(models.ShortenRequest{URL: "https://blocked.example/"})
+	live := shorten(models.ShortenRequest{URL: "https://live.example/"})
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("blocked.example\n"), 0o600))
+	rules, err := blocklist.Start(path, time.Hour, *zap.NewNop().Sugar())
+	require.NoError(t, err)
+	defer rules.Stop()
+	handler.SetBlocklist(rules, http.StatusUnavailableForLegalReasons)
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{id: "missing1", want: http.StatusNotFound},
+		{id: deleted, want: http.StatusGone},
+		{id: expired, want: http.StatusGone},
+		{id: blocked, want: http.StatusUnavailableForLegalReasons},
+	}
+	for _, tt := range tests {
+		get := httptest.NewRecorder()
+		handler.GetHandler(get, httptest.NewRequest(http.MethodGet, "/"+tt.id, nil).WithContext(ctx))
+		qr := httptest.NewRecorder()
+		handler.GetQRHandler(qr, httptest.NewRequest(http.MethodGet, "/"+tt.id+"/qr", nil).WithContext(ctx))
+
+		assert.Equal(t, tt.want, get.Code, tt.id)
+		assert.Equal(t, tt.want, qr.Code, tt.id)
+	}
+
+	get := httptest.NewRecorder()
+	handler.GetHandler(get, httptest.NewRequest(http.MethodGet, "/"+live, nil).WithContext(ctx))
+	assert.Equal(t, http.StatusTemporaryRedirect, get.Code)
+}
@@ -2,22 +2,15 @@ package app
 
 import (
 	"context"
-	"database/sql"
 	"net/http"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-type (
-	key    string
-	logger string
-)
+type logger string
 
-const (
-	dbKey     key    = "database"
-	loggerKey logger = "sugar"
-)
+const loggerKey logger = "sugar"
 
 type (
 	// Берём структуру для хранения сведений об ответе
@@ -49,12 +42,11 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 }
 
 // WithLogging мидлварь, отвечающая за логирование запросов и ответов
-func WithLogging(db *sql.DB, h http.HandlerFunc, logger zap.SugaredLogger) http.HandlerFunc {
+func WithLogging(h http.HandlerFunc, logger zap.SugaredLogger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now() // Засекаем
 
-		ctx := context.WithValue(r.Context(), dbKey, db)
-		ctx = context.WithValue(ctx, loggerKey, logger)
+		ctx := context.WithValue(r.Context(), loggerKey, logger)
 
 		responseData := &responseData{
 			status: 0,
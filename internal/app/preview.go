@@ -0,0 +1,115 @@
+package app
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/internal/store"
+	"github.com/JohnnyConstantin/urlshort/internal/urlnorm"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// previewSuffix суффикс короткого идентификатора, по которому вместо редиректа отдается страница предпросмотра
+const previewSuffix = "+"
+
+//go:embed templates/*.html
+var templates embed.FS
+
+// previewTemplate страница предпросмотра и промежуточная страница перед переходом
+var previewTemplate = template.Must(template.ParseFS(templates, "templates/preview.html"))
+
+// previewPage данные страницы предпросмотра
+type previewPage struct {
+	ShortURL     string
+	OriginalURL  string
+	Domain       string
+	Title        string
+	CreatedAt    *time.Time // nil у ссылок, созданных до появления поля
+	Interstitial bool       // Страница показана вместо редиректа
+}
+
+// Interstitial решает, когда вместо редиректа показывать промежуточную страницу. Ссылка с флагом Interstitial
+// показывает ее всегда, а при force - все ссылки, кроме ведущих на доверенные домены и их поддомены
+type Interstitial struct {
+	force   bool
+	trusted map[string]bool
+}
+
+// NewInterstitial создает настройки промежуточной страницы
+func NewInterstitial(force bool, trusted []string) (*Interstitial, error) {
+	i := &Interstitial{force: force, trusted: make(map[string]bool, len(trusted))}
+	for _, domain := range trusted {
+		ascii, err := urlnorm.Domain(domain)
+		if err != nil {
+			return nil, fmt.Errorf("trusted domain %q: %w", domain, err)
+		}
+		i.trusted[ascii] = true
+	}
+	return i, nil
+}
+
+// required нужна ли промежуточная страница для перехода по ссылке. На nil учитывается только флаг самой ссылки
+func (i *Interstitial) required(record models.URLRecord) bool {
+	if record.Interstitial {
+		return true
+	}
+	if i == nil || !i.force {
+		return false
+	}
+
+	u, err := url.Parse(record.OriginalURL)
+	if err != nil {
+		return true
+	}
+	for host := strings.ToLower(u.Hostname()); host != ""; {
+		if i.trusted[host] {
+			return false
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return true
+}
+
+// renderPreview отдает HTML страницу со сведениями о ссылке. interstitial - страница показана вместо редиректа
+func renderPreview(w http.ResponseWriter, sugar zap.SugaredLogger, record models.URLRecord, interstitial bool) {
+	page := previewPage{
+		ShortURL:     buildShortURL(record.ShortURL),
+		OriginalURL:  record.OriginalURL,
+		Title:        record.Title,
+		CreatedAt:    record.CreatedAt,
+		Interstitial: interstitial,
+	}
+	if u, err := url.Parse(record.OriginalURL); err == nil {
+		page.Domain = u.Hostname()
+	}
+	if page.Domain == "" {
+		page.Domain = record.OriginalURL
+	}
+
+	// Шаблон исполняется в буфер, чтобы ошибка не оставила клиенту половину страницы
+	var buf bytes.Buffer
+	if err := previewTemplate.Execute(&buf, page); err != nil {
+		sugar.Errorf("Error in rendering preview of %s: %v", record.ShortURL, err)
+		http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		sugar.Errorf("Error in writing response body: %v", err)
+	}
+}
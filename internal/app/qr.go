@@ -0,0 +1,154 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/internal/qrcode"
+	"github.com/JohnnyConstantin/urlshort/internal/store"
+)
+
+// Параметры QR-кода по умолчанию и допустимые границы
+const (
+	qrDefaultSize = 256 // Сторона картинки в пикселях
+	qrMinSize     = 32
+	qrMaxSize     = 2048
+	qrMaxMargin   = 16 // Поле в модулях, по умолчанию qrcode.DefaultMargin
+)
+
+// qrParams параметры запроса QR-кода
+type qrParams struct {
+	format string // png или svg
+	size   int
+	level  qrcode.Level
+	margin int
+}
+
+// parseQRParams разбирает format, size, ecc и margin из строки запроса
+func parseQRParams(r *http.Request) (qrParams, error) {
+	query := r.URL.Query()
+	params := qrParams{format: "png", size: qrDefaultSize, level: qrcode.Medium, margin: qrcode.DefaultMargin}
+
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format != "png" && format != "svg" {
+			return qrParams{}, fmt.Errorf("invalid format %q: must be png or svg", format)
+		}
+		params.format = format
+	}
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < qrMinSize || n > qrMaxSize {
+			return qrParams{}, fmt.Errorf("invalid size %q: must be between %d and %d", size, qrMinSize, qrMaxSize)
+		}
+		params.size = n
+	}
+	if ecc := query.Get("ecc"); ecc != "" {
+		level, err := qrcode.ParseLevel(ecc)
+		if err != nil {
+			return qrParams{}, err
+		}
+		params.level = level
+	}
+	if margin := query.Get("margin"); margin != "" {
+		n, err := strconv.Atoi(margin)
+		if err != nil || n < 0 || n > qrMaxMargin {
+			return qrParams{}, fmt.Errorf("invalid margin %q: must be between 0 and %d", margin, qrMaxMargin)
+		}
+		params.margin = n
+	}
+	return params, nil
+}
+
+// GetQRHandler отдает QR-код полного короткого URL: GET /{id}/qr?format=png|svg&size=256&ecc=M&margin=4.
+// Неизвестная, удаленная, истекшая и ведущая на запрещенный домен ссылка отдают те же статусы, что и GetHandler
+func (h *Handler) GetQRHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sugar, ok := ctx.Value(loggerKey).(zap.SugaredLogger)
+	if !ok {
+		http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[1] != "qr" {
+		http.Error(w, store.BadRequestError, store.DefaultErrorCode)
+		return
+	}
+	id := parts[0]
+
+	params, err := parseQRParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), store.DefaultErrorCode)
+		return
+	}
+
+	record, err := h.repo.Resolve(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, store.NotFoundError, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sugar.Errorf("Error in resolving short URL %s: %v", id, err)
+		writeStoreError(w, err, store.InternalSeverErrorCode)
+		return
+	}
+	if record.DeletedFlag || record.Expired(time.Now()) {
+		http.Error(w, "Gone", http.StatusGone)
+		return
+	}
+	if _, blocked := h.blocked.Match(record.OriginalURL); blocked {
+		http.Error(w, store.BlockedError, h.blockedStatus)
+		return
+	}
+
+	// Картинка зависит только от короткого URL и параметров, поэтому ETag считается без построения кода.
+	// Кэшировать можно, но каждый запрос перепроверяется: ссылку могут удалить или заблокировать,
+	// и тогда вместо 304 клиент должен получить ошибку выше
+	shortURL := buildShortURL(id)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s|%d", shortURL, params.format, params.size, params.level, params.margin)))
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	code, err := qrcode.Encode([]byte(shortURL), params.level)
+	if err != nil {
+		sugar.Errorf("Error in encoding QR code for %s: %v", id, err)
+		http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if params.format == "svg" {
+		contentType = "image/svg+xml"
+		err = code.SVG(&buf, params.size, params.margin)
+	} else {
+		err = code.PNG(&buf, params.size, params.margin)
+	}
+	if err != nil {
+		sugar.Errorf("Error in rendering QR code for %s: %v", id, err)
+		http.Error(w, store.DefaultError, store.InternalSeverErrorCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		sugar.Errorf("Error in writing response body: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JohnnyConstantin/urlshort/internal/store"
+	"github.com/JohnnyConstantin/urlshort/internal/urlnorm"
+)
+
+// Режимы обработки сокращаемых URL, которые указывают на сам сервис
+const (
+	SelfLinksResolve = "resolve" // Короткая ссылка заменяется конечным URL своей цепочки
+	SelfLinksReject  = "reject"  // Ссылки на сервис не сокращаются
+)
+
+// errSelfLink сокращаемый URL указывает на сам сервис и не может быть сокращен. Текст отдается клиенту
+var errSelfLink = errors.New("url points to this shortener")
+
+// SelfLinks распознает URL, ведущие на сам сервис, чтобы из коротких ссылок нельзя было собрать петлю или длинную цепочку
+type SelfLinks struct {
+	bases    []*url.URL // Базовые адреса сервиса в нормализованном виде
+	resolve  bool
+	maxDepth int
+}
+
+// NewSelfLinks создает проверку для базовых адресов baseURLs. mode - SelfLinksResolve или SelfLinksReject,
+// maxDepth ограничивает длину раскрываемой цепочки
+func NewSelfLinks(baseURLs []string, mode string, maxDepth int) (*SelfLinks, error) {
+	if mode != SelfLinksResolve && mode != SelfLinksReject {
+		return nil, fmt.Errorf("invalid self links mode %q: must be %s or %s", mode, SelfLinksResolve, SelfLinksReject)
+	}
+	if maxDepth < 1 {
+		return nil, fmt.Errorf("invalid max link depth %d: must be positive", maxDepth)
+	}
+
+	// Базовые адреса приводятся к той же форме, что и сокращаемые URL, иначе их нельзя сравнить
+	norm, err := urlnorm.New(urlnorm.DefaultSchemes, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SelfLinks{resolve: mode == SelfLinksResolve, maxDepth: maxDepth}
+	for _, base := range baseURLs {
+		normalized, err := norm.Normalize(base)
+		if err != nil {
+			return nil, fmt.Errorf("base url %q: %w", base, err)
+		}
+		u, _ := url.Parse(normalized) // Нормализованный URL заведомо разбирается
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		s.bases = append(s.bases, u)
+	}
+	return s, nil
+}
+
+// shortID проверяет, указывает ли нормализованный target на сервис. Для короткой ссылки возвращает ее идентификатор,
+// для других путей сервиса (API, корень) - пустую строку
+func (s *SelfLinks) shortID(target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+
+	for _, base := range s.bases {
+		// Схема не сравнивается: по http и https отвечает один и тот же сервис
+		if u.Host != base.Host {
+			continue
+		}
+		rest, ok := strings.CutPrefix(u.Path, base.Path)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+
+		// Так же, как GetHandler: редиректит только путь из одного сегмента
+		rest = strings.Trim(rest, "/")
+		if rest == "" || strings.Contains(rest, "/") {
+			return "", true
+		}
+		return rest, true
+	}
+	return "", false
+}
+
+// resolveSelfLink возвращает URL, который можно сохранить вместо target. Ссылка на сервис отклоняется или раскрывается
+// по цепочке коротких ссылок до внешнего URL. Ошибка с errSelfLink объясняет клиенту причину отказа, остальные
+// ошибки - ошибки хранилища
+func (h *Handler) resolveSelfLink(ctx context.Context, target string) (string, error) {
+	if h.self == nil {
+		return target, nil
+	}
+
+	seen := make(map[string]bool)
+	for {
+		id, self := h.self.shortID(target)
+		switch {
+		case !self:
+			return target, nil
+		case !h.self.resolve || id == "":
+			return "", errSelfLink
+		case seen[id]:
+			return "", fmt.Errorf("%w: short links form a loop", errSelfLink)
+		case len(seen) == h.self.maxDepth:
+			return "", fmt.Errorf("%w: chain of short links is longer than %d", errSelfLink, h.self.maxDepth)
+		}
+		seen[id] = true
+
+		record, err := h.repo.Resolve(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return "", fmt.Errorf("%w: short link %q does not exist", errSelfLink, id)
+		}
+		if err != nil {
+			return "", err
+		}
+		if record.DeletedFlag || record.Expired(time.Now()) {
+			return "", fmt.Errorf("%w: short link %q is gone", errSelfLink, id)
+		}
+		target = record.OriginalURL
+	}
+}
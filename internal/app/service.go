@@ -1,12 +1,48 @@
 package app
 
 import (
-	"github.com/JohnnyConstantin/urlshort/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JohnnyConstantin/urlshort/internal/config"
 )
 
-// Shortener интерфейс для разных функций бизнес-логики в зависимости от используемого StorageType
-type Shortener interface {
-	ShortenURL(originalURL string) models.ShortenResponse    // Используется для сжатия URL, используя оригинал
-	GetFullURL(shortID string) (models.ShortenRequest, bool) // Используется для получения полного URL, используя короткий
-	DeleteURL(userID string) error
+// buildShortURL собирает полный сокращенный URL из короткого идентификатора
+func buildShortURL(shortID string) string {
+	return config.Options.BaseAddress + "/" + shortID
+}
+
+// maxTitleLength максимальная длина названия ссылки в символах
+const maxTitleLength = 200
+
+// validateTitle обрезает пробелы по краям названия ссылки и проверяет длину и отсутствие управляющих символов
+func validateTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return "", fmt.Errorf("title must be at most %d characters", maxTitleLength)
+	}
+	if !utf8.ValidString(title) || strings.ContainsFunc(title, unicode.IsControl) {
+		return "", errors.New("title must not contain control characters")
+	}
+	return title, nil
+}
+
+// resolveExpiry приводит expires_at или ttl из запроса к моменту истечения. nil - ссылка бессрочная
+func resolveExpiry(expiresAt *time.Time, ttl int64, now time.Time) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && ttl != 0:
+		return nil, errors.New("only one of expires_at and ttl may be set")
+	case ttl < 0:
+		return nil, errors.New("ttl must be positive")
+	case ttl > 0:
+		t := now.Add(time.Duration(ttl) * time.Second)
+		return &t, nil
+	case expiresAt != nil && !expiresAt.After(now):
+		return nil, errors.New("expires_at must be in the future")
+	}
+	return expiresAt, nil
 }
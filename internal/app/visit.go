@@ -0,0 +1,88 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JohnnyConstantin/urlshort/internal/useragent"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Сколько старших бит адреса клиента сохраняется в журнале посещений: сеть /24 для IPv4 и /48 для IPv6
+const (
+	visitIPv4Bits = 24
+	visitIPv6Bits = 48
+)
+
+// newVisit собирает событие перехода из запроса на редирект
+func newVisit(r *http.Request, shortID string, at time.Time) models.Visit {
+	ua := useragent.Parse(r.UserAgent())
+
+	return models.Visit{
+		ShortURL: shortID,
+		At:       at,
+		Referrer: refererHost(r.Referer()),
+		Browser:  ua.Browser,
+		OS:       ua.OS,
+		Device:   ua.Device,
+		Language: primaryLanguage(r.Header.Get("Accept-Language")),
+		IP:       truncateIP(r.RemoteAddr),
+		Bot:      ua.Bot,
+	}
+}
+
+// refererHost оставляет от Referer только хост: пути и параметры могут содержать персональные данные
+func refererHost(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// primaryLanguage возвращает основной язык с наибольшим весом из Accept-Language: "ru-RU,ru;q=0.9,en;q=0.8" -> "ru"
+func primaryLanguage(header string) string {
+	best, bestQ := "", -1.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+
+	lang, _, _ := strings.Cut(best, "-")
+	return strings.ToLower(lang)
+}
+
+// truncateIP обнуляет младшую часть адреса клиента, чтобы журнал не хранил точный IP
+func truncateIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(visitIPv4Bits, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(visitIPv6Bits, 128)).String()
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7", want: "ru"},
+		{header: "en;q=0.5, de-DE", want: "de"},
+		{header: "*", want: ""},
+		{header: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, primaryLanguage(tt.header))
+		})
+	}
+}
+
+func TestTruncateIP(t *testing.T) {
+	assert.Equal(t, "203.0.113.0", truncateIP("203.0.113.77:52311"))
+	assert.Equal(t, "2001:db8:85a3::", truncateIP("[2001:db8:85a3:8d3:1319:8a2e:370:7348]:443"))
+	assert.Equal(t, "", truncateIP("garbage"))
+}
+
+func TestNewVisit(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	r.RemoteAddr = "198.51.100.23:1234"
+	r.Header.Set("Referer", "https://www.Example.com/path?token=secret")
+	r.Header.Set("User-Agent", "facebookexternalhit/1.1")
+	now := time.Now()
+
+	visit := newVisit(r, "abc", now)
+	assert.Equal(t, "abc", visit.ShortURL)
+	assert.Equal(t, "example.com", visit.Referrer)
+	assert.Equal(t, "198.51.100.0", visit.IP)
+	assert.Equal(t, "Facebook", visit.Bot)
+}
@@ -0,0 +1,266 @@
+// Package blocklist проверяет адреса по списку запрещенных доменов и IP. Правила читаются из файла и перечитываются
+// по SIGHUP или при изменении файла, без перезапуска сервера
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/internal/urlnorm"
+)
+
+// ReloadInterval период проверки файла правил на изменения
+const ReloadInterval = 2 * time.Second
+
+// Rules скомпилированный набор правил. Формат файла - одно правило в строке, # начинает комментарий:
+//
+//	example.com       - ровно этот хост
+//	*.example.com     - любые поддомены example.com (сам example.com не входит)
+//	/^bank-.*\.com$/  - регулярное выражение по хосту
+//	203.0.113.0/24    - подсеть для URL с IP вместо хоста, одиночный IP тоже допустим
+type Rules struct {
+	exact    map[string]string // Хост: исходный текст правила
+	suffixes map[string]string // Родительский домен wildcard-правила: исходный текст правила
+	regexps  []rule[*regexp.Regexp]
+	prefixes []rule[netip.Prefix]
+}
+
+// rule правило с исходным текстом, который возвращается из Match
+type rule[T any] struct {
+	text    string
+	pattern T
+}
+
+// Parse разбирает правила из r. Ошибка указывает номер строки, частично разобранный набор не возвращается
+func Parse(r io.Reader) (*Rules, error) {
+	rules := &Rules{exact: make(map[string]string), suffixes: make(map[string]string)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if err := rules.add(text); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Load читает правила из файла
+func Load(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+func (r *Rules) add(text string) error {
+	switch {
+	case len(text) > 1 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/"):
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return fmt.Errorf("invalid regexp %s: %w", text, err)
+		}
+		r.regexps = append(r.regexps, rule[*regexp.Regexp]{text: text, pattern: re})
+
+	case strings.Contains(text, "/"):
+		prefix, err := netip.ParsePrefix(text)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %s: %w", text, err)
+		}
+		r.prefixes = append(r.prefixes, rule[netip.Prefix]{text: text, pattern: prefix.Masked()})
+
+	default:
+		if addr, err := netip.ParseAddr(text); err == nil {
+			r.prefixes = append(r.prefixes, rule[netip.Prefix]{text: text, pattern: netip.PrefixFrom(addr, addr.BitLen())})
+			return nil
+		}
+
+		parent, wildcard := strings.CutPrefix(text, "*.")
+		domain, err := urlnorm.Domain(parent)
+		if err != nil {
+			return fmt.Errorf("invalid domain %s", text)
+		}
+		if wildcard {
+			r.suffixes[domain] = text
+		} else {
+			r.exact[domain] = text
+		}
+	}
+	return nil
+}
+
+// Len количество правил
+func (r *Rules) Len() int {
+	return len(r.exact) + len(r.suffixes) + len(r.regexps) + len(r.prefixes)
+}
+
+// Match проверяет хост URL rawURL. Возвращает сработавшее правило. URL без хоста не блокируется
+func (r *Rules) Match(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		for _, p := range r.prefixes {
+			if p.pattern.Contains(addr) {
+				return p.text, true
+			}
+		}
+		return "", false
+	}
+
+	if text, ok := r.exact[host]; ok {
+		return text, true
+	}
+	for parent := host; ; {
+		_, rest, found := strings.Cut(parent, ".")
+		if !found {
+			break
+		}
+		if text, ok := r.suffixes[rest]; ok {
+			return text, true
+		}
+		parent = rest
+	}
+	for _, re := range r.regexps {
+		if re.pattern.MatchString(host) {
+			return re.text, true
+		}
+	}
+	return "", false
+}
+
+// Blocklist правила из файла с перечитыванием на лету. Ошибка в новом файле не сбрасывает действующие правила
+type Blocklist struct {
+	path   string
+	rules  atomic.Pointer[Rules]
+	logger zap.SugaredLogger
+
+	mu      sync.Mutex // Сериализует перечитывание по сигналу и по таймеру
+	modTime time.Time
+	size    int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start загружает правила из path и запускает их перечитывание по SIGHUP и при изменении файла раз в interval
+func Start(path string, interval time.Duration, logger zap.SugaredLogger) (*Blocklist, error) {
+	b := &Blocklist{
+		path:   path,
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+
+	go b.run(interval)
+
+	return b, nil
+}
+
+// Match проверяет URL по действующим правилам. На nil всегда возвращает false, так что список можно не настраивать
+func (b *Blocklist) Match(rawURL string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	return b.rules.Load().Match(rawURL)
+}
+
+// Reload перечитывает файл правил
+func (b *Blocklist) Reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return err
+	}
+	b.modTime, b.size = info.ModTime(), info.Size() // Сломанный файл не перечитывается, пока его снова не изменят
+
+	rules, err := Load(b.path)
+	if err != nil {
+		return fmt.Errorf("blocklist %s: %w", b.path, err)
+	}
+
+	b.rules.Store(rules)
+	b.logger.Infow("Blocklist loaded", "file", b.path, "rules", rules.Len())
+	return nil
+}
+
+// Stop останавливает перечитывание
+func (b *Blocklist) Stop() {
+	close(b.stop)
+	<-b.done
+}
+
+func (b *Blocklist) run(interval time.Duration) {
+	defer close(b.done)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-hup:
+			b.reload()
+		case <-ticker.C:
+			if b.changed() {
+				b.reload()
+			}
+		}
+	}
+}
+
+func (b *Blocklist) reload() {
+	if err := b.Reload(); err != nil {
+		b.logger.Errorf("Blocklist reload failed, keeping previous rules: %v", err)
+	}
+}
+
+// changed сравнивает время изменения и размер файла с загруженными
+func (b *Blocklist) changed() bool {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false // Файл могут заменять переименованием, дождемся его появления
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !info.ModTime().Equal(b.modTime) || info.Size() != b.size
+}
@@ -0,0 +1,109 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const testRules = `
+# Комментарии и пустые строки пропускаются
+evil.example
+*.phish.example   # только поддомены
+Пример.испытание
+/^bank-[a-z]+\.com$/
+203.0.113.0/24
+198.51.100.7
+2001:db8::/32
+`
+
+func TestRulesMatch(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testRules))
+	require.NoError(t, err)
+	assert.Equal(t, 7, rules.Len())
+
+	tests := []struct {
+		url  string
+		rule string
+	}{
+		{url: "https://evil.example/path", rule: "evil.example"},
+		{url: "https://EVIL.example./", rule: "evil.example"},
+		{url: "https://sub.evil.example/", rule: ""},
+		{url: "https://phish.example/", rule: ""},
+		{url: "https://login.phish.example/", rule: "*.phish.example"},
+		{url: "https://a.b.phish.example/", rule: "*.phish.example"},
+		{url: "https://notphish.example/", rule: ""},
+		{url: "https://xn--e1afmkfd.xn--80akhbyknj4f/", rule: "Пример.испытание"},
+		{url: "https://bank-secure.com/", rule: `/^bank-[a-z]+\.com$/`},
+		{url: "https://bank-secure.com.example/", rule: ""},
+		{url: "http://203.0.113.42:8080/", rule: "203.0.113.0/24"},
+		{url: "http://203.0.114.1/", rule: ""},
+		{url: "http://198.51.100.7/", rule: "198.51.100.7"},
+		{url: "http://[2001:db8::1]/", rule: "2001:db8::/32"},
+		{url: "http://[::ffff:203.0.113.1]/", rule: "203.0.113.0/24"},
+		{url: "mailto:user@evil.example", rule: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			rule, ok := rules.Match(tt.url)
+			assert.Equal(t, tt.rule != "", ok)
+			assert.Equal(t, tt.rule, rule)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{rules: "ok.example\n/[/", err: "line 2: invalid regexp"},
+		{rules: "10.0.0.0/33", err: "line 1: invalid CIDR"},
+		{rules: "\n\nbad_-.example-", err: "line 3: invalid domain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.rules))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.example\n"), 0o600))
+
+	b, err := Start(path, 10*time.Millisecond, *zaptest.NewLogger(t).Sugar())
+	require.NoError(t, err)
+	defer b.Stop()
+
+	_, ok := b.Match("https://evil.example/")
+	assert.True(t, ok)
+	_, ok = b.Match("https://other.example/")
+	assert.False(t, ok)
+
+	// Новое правило подхватывается без перезапуска
+	require.NoError(t, os.WriteFile(path, []byte("evil.example\nother.example\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		_, ok := b.Match("https://other.example/")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	// Сломанный файл не сбрасывает действующие правила
+	require.NoError(t, os.WriteFile(path, []byte("/[/\n"), 0o600))
+	assert.Error(t, b.Reload())
+	_, ok = b.Match("https://other.example/")
+	assert.True(t, ok)
+}
+
+func TestNilBlocklist(t *testing.T) {
+	var b *Blocklist
+	_, ok := b.Match("https://evil.example/")
+	assert.False(t, ok)
+}
@@ -4,9 +4,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 )
 
 // StorageType тип хранилища
@@ -17,6 +20,8 @@ const (
 	StorageMemory StorageType = "memory"
 	StorageFile   StorageType = "file"
 	StorageDB     StorageType = "postgres"
+	StorageSQLite StorageType = "sqlite"
+	StorageBolt   StorageType = "bolt"
 )
 
 // внутренние параметры для разработчика (предсказал их появление на первом же спринте xD)
@@ -29,32 +34,128 @@ var (
 
 // Options опции запуска сервера
 var Options struct {
-	Address     string
-	BaseAddress string
-	DSN         string
-	FileToWrite string
-	SecretKey   string
-	Config      string // Добвалена опция для конфига
-	EnableHTTPS bool   // Добавлена опция на HTTPS
+	Address           string
+	BaseAddress       string
+	DSN               string
+	FileToWrite       string
+	FileCompactSize   int64         // Размер журнала в байтах, с которого он компактится автоматически
+	FileCompactRatio  float64       // Доля устаревших строк журнала для автоматической компакции, 0 - только по SIGHUP
+	SQLitePath        string        // Путь к файлу SQLite
+	BoltPath          string        // Путь к файлу bbolt
+	IDStrategy        string        // Стратегия генерации коротких идентификаторов: random, sequence или hash
+	IDLength          int           // Начальная длина короткого идентификатора
+	IDAlphabet        string        // Алфавит короткого идентификатора
+	ReapInterval      time.Duration // Период очистки истекших ссылок, 0 - не чистить
+	CacheSize         int           // Размер кэша редиректов, 0 - без кэша
+	CacheTTL          time.Duration // Время жизни найденной ссылки в кэше
+	CacheNegTTL       time.Duration // Время жизни отрицательного результата в кэше, 0 - не кэшировать
+	ReplicaDSNs       []string      // DSN реплик PostgreSQL для чтения
+	ReadWindow        time.Duration // Сколько после записи пользователь читает с основной базы
+	ReadTimeout       time.Duration // Таймаут чтения из хранилища, 0 - без ограничения
+	WriteTimeout      time.Duration // Таймаут записи в хранилище, 0 - без ограничения
+	DBPgxpool         bool          // Пул соединений pgxpool вместо database/sql
+	DBMaxOpenConns    int           // Максимум открытых соединений, 0 - по умолчанию драйвера
+	DBMaxIdleConns    int           // Максимум простаивающих соединений
+	DBConnMaxLifetime time.Duration // Время жизни соединения, 0 - без ограничения
+	DBConnMaxIdleTime time.Duration // Время простоя соединения до закрытия, 0 - без ограничения
+	DBHealthCheck     time.Duration // Период проверки соединений pgxpool
+	DBStatementCache  int           // Размер кэша подготовленных выражений, 0 - без кэша
+	DBRetryBudget     time.Duration // Время на повторы операции при временных ошибках, 0 - без повторов
+	AllowedSchemes    []string      // Схемы, которые разрешено сокращать
+	StripTracking     bool          // Убирать utm_* параметры из сокращаемых URL
+	BlocklistPath     string        // Файл со списком запрещенных доменов, пусто - без проверки
+	BlocklistStatus   int           // Ответ на переход по ссылке на запрещенный домен: 451 или 410
+	SelfURLs          []string      // Другие адреса, по которым доступен сервис, кроме BaseAddress
+	SelfLinks         string        // Что делать с сокращаемыми ссылками на сам сервис: resolve или reject
+	MaxLinkDepth      int           // Максимальная длина раскрываемой цепочки коротких ссылок
+	ForceInterstitial bool          // Показывать промежуточную страницу перед переходом на внешние домены
+	TrustedDomains    []string      // Домены, на которые переход идет сразу даже при ForceInterstitial
+	SecretKey         string
+	Config            string // Добвалена опция для конфига
+	EnableHTTPS       bool   // Добавлена опция на HTTPS
 }
 
 func DefaultConfig() *JSONConfig {
 	return &JSONConfig{
-		ServerAddress:   "localhost:8080",
-		BaseURL:         "http://localhost:8080",
-		FileStoragePath: "",
-		DatabaseDSN:     "",
-		EnableHTTPS:     false,
+		ServerAddress:     "localhost:8080",
+		BaseURL:           "http://localhost:8080",
+		FileStoragePath:   "",
+		FileCompactSize:   1 << 20,
+		FileCompactRatio:  0.5,
+		DatabaseDSN:       "",
+		SQLitePath:        "",
+		BoltPath:          "",
+		IDStrategy:        "random",
+		IDLength:          8,
+		IDAlphabet:        "",
+		ReapInterval:      "1m",
+		CacheSize:         10000,
+		CacheTTL:          "1m",
+		CacheNegTTL:       "5s",
+		ReplicaDSNs:       nil,
+		ReadWindow:        "5s",
+		ReadTimeout:       "2s",
+		WriteTimeout:      "5s",
+		DBPgxpool:         false,
+		DBMaxOpenConns:    0,
+		DBMaxIdleConns:    2,
+		DBConnMaxLifetime: "0s",
+		DBConnMaxIdleTime: "0s",
+		DBHealthCheck:     "1m",
+		DBStatementCache:  512,
+		DBRetryBudget:     "2s",
+		AllowedSchemes:    []string{"http", "https"},
+		StripTracking:     false,
+		BlocklistPath:     "",
+		BlocklistStatus:   451,
+		SelfURLs:          nil,
+		SelfLinks:         "resolve",
+		MaxLinkDepth:      5,
+		ForceInterstitial: false,
+		TrustedDomains:    nil,
+		EnableHTTPS:       false,
 	}
 }
 
 // JSONConfig JSON конфиг для опций
 type JSONConfig struct {
-	ServerAddress   string `json:"server_address"`
-	BaseURL         string `json:"base_url"`
-	FileStoragePath string `json:"file_storage_path"`
-	DatabaseDSN     string `json:"database_dsn"`
-	EnableHTTPS     bool   `json:"enable_https"`
+	ServerAddress     string   `json:"server_address"`
+	BaseURL           string   `json:"base_url"`
+	FileStoragePath   string   `json:"file_storage_path"`
+	FileCompactSize   int64    `json:"file_compact_min_size"`
+	FileCompactRatio  float64  `json:"file_compact_garbage_ratio"`
+	DatabaseDSN       string   `json:"database_dsn"`
+	SQLitePath        string   `json:"sqlite_path"`
+	BoltPath          string   `json:"bolt_path"`
+	IDStrategy        string   `json:"id_strategy"`
+	IDLength          int      `json:"id_length"`
+	IDAlphabet        string   `json:"id_alphabet"`
+	ReapInterval      string   `json:"reap_interval"` // Длительность в формате time.ParseDuration, например "1m"
+	CacheSize         int      `json:"cache_size"`
+	CacheTTL          string   `json:"cache_ttl"`
+	CacheNegTTL       string   `json:"cache_negative_ttl"`
+	ReplicaDSNs       []string `json:"database_replica_dsns"`
+	ReadWindow        string   `json:"read_after_write_window"`
+	ReadTimeout       string   `json:"db_read_timeout"`
+	WriteTimeout      string   `json:"db_write_timeout"`
+	DBPgxpool         bool     `json:"db_pgxpool"`
+	DBMaxOpenConns    int      `json:"db_max_open_conns"`
+	DBMaxIdleConns    int      `json:"db_max_idle_conns"`
+	DBConnMaxLifetime string   `json:"db_conn_max_lifetime"`
+	DBConnMaxIdleTime string   `json:"db_conn_max_idle_time"`
+	DBHealthCheck     string   `json:"db_health_check_period"`
+	DBStatementCache  int      `json:"db_statement_cache"`
+	DBRetryBudget     string   `json:"db_retry_budget"`
+	AllowedSchemes    []string `json:"allowed_schemes"`
+	StripTracking     bool     `json:"strip_tracking_params"`
+	BlocklistPath     string   `json:"blocklist_path"`
+	BlocklistStatus   int      `json:"blocklist_status"`
+	SelfURLs          []string `json:"self_urls"`
+	SelfLinks         string   `json:"self_links"`
+	MaxLinkDepth      int      `json:"max_link_depth"`
+	ForceInterstitial bool     `json:"force_interstitial"`
+	TrustedDomains    []string `json:"trusted_domains"`
+	EnableHTTPS       bool     `json:"enable_https"`
 }
 
 // Config Объект глобального конфига
@@ -64,7 +165,8 @@ var Config StorageConfig
 type StorageConfig struct {
 	StorageType StorageType
 	DatabaseDSN string // DSN для PostgreSQL (опциональное)
-	FilePath    string // Путь к файлу (опциональное)
+	FilePath    string // Путь к файлу JSONL, SQLite или bbolt (опциональное)
+	ImportPath  string // JSONL файл для первичного импорта в bbolt (опциональное)
 }
 
 // LoadConfigFromFile инициализирует JSON конфигурацию
@@ -84,30 +186,64 @@ func LoadConfigFromFile(filename string) (*JSONConfig, error) {
 	return config, nil
 }
 
-// LoadJSONConfig загружает конфигурацию из JSON файла
-func LoadJSONConfig() {
+// LoadJSONConfig загружает конфигурацию из JSON файла. Возвращает ошибки значений, которые не удалось разобрать
+func LoadJSONConfig() error {
 	configFilePath := getConfigFilePath()
 	if configFilePath == "" {
-		return // Файл конфигурации не указан
+		return nil // Файл конфигурации не указан
 	}
 
 	jsonConfig, err := LoadConfigFromFile(configFilePath)
 	if err != nil {
-		return
+		return err // Тип значения не совпал с ожидаемым (например, "cache_size": "10k") - тоже сюда
 	}
 
 	// Применяем JSON конфиг с учетом того, что флаги имеют приоритет выше, чем конфига
-	ApplyJSONConfig(jsonConfig, flag.Parsed())
+	return ApplyJSONConfig(jsonConfig, flag.Parsed())
 }
 
 // ApplyJSONConfig применяет значения из JSON конфига к глобальным Options
-// с учетом того, что флаги и env имеют высший приоритет
-func ApplyJSONConfig(jsonConfig *JSONConfig, flagsParsed bool) {
+// с учетом того, что флаги и env имеют высший приоритет. Возвращает ошибки всех значений, которые не удалось разобрать
+func ApplyJSONConfig(jsonConfig *JSONConfig, flagsParsed bool) error {
+	var errs []error
+
 	// Применяем значения только если они не были установлены флагами (env потом сам перезапишет значения в main)
 	addressSet := isFlagSet("a")
 	baseAddressSet := isFlagSet("b")
 	fileToWriteSet := isFlagSet("f")
+	fileCompactSizeSet := isFlagSet("file-compact-min-size")
+	fileCompactRatioSet := isFlagSet("file-compact-garbage-ratio")
 	dsnSet := isFlagSet("d")
+	sqlitePathSet := isFlagSet("sqlite-path")
+	boltPathSet := isFlagSet("bolt-path")
+	idStrategySet := isFlagSet("id-strategy")
+	idLengthSet := isFlagSet("id-length")
+	idAlphabetSet := isFlagSet("id-alphabet")
+	reapIntervalSet := isFlagSet("reap-interval")
+	cacheSizeSet := isFlagSet("cache-size")
+	cacheTTLSet := isFlagSet("cache-ttl")
+	cacheNegTTLSet := isFlagSet("cache-negative-ttl")
+	replicaDSNsSet := isFlagSet("replica-dsn")
+	readWindowSet := isFlagSet("read-after-write-window")
+	readTimeoutSet := isFlagSet("db-read-timeout")
+	writeTimeoutSet := isFlagSet("db-write-timeout")
+	dbPgxpoolSet := isFlagSet("db-pgxpool")
+	dbMaxOpenSet := isFlagSet("db-max-open-conns")
+	dbMaxIdleSet := isFlagSet("db-max-idle-conns")
+	dbLifetimeSet := isFlagSet("db-conn-max-lifetime")
+	dbIdleTimeSet := isFlagSet("db-conn-max-idle-time")
+	dbHealthCheckSet := isFlagSet("db-health-check-period")
+	dbStmtCacheSet := isFlagSet("db-statement-cache")
+	dbRetryBudgetSet := isFlagSet("db-retry-budget")
+	allowedSchemesSet := isFlagSet("allowed-schemes")
+	stripTrackingSet := isFlagSet("strip-tracking-params")
+	blocklistSet := isFlagSet("blocklist")
+	blocklistStatusSet := isFlagSet("blocklist-status")
+	selfURLsSet := isFlagSet("self-urls")
+	selfLinksSet := isFlagSet("self-links")
+	maxLinkDepthSet := isFlagSet("max-link-depth")
+	forceInterstitialSet := isFlagSet("force-interstitial")
+	trustedDomainsSet := isFlagSet("trusted-domains")
 	enableHTTPSSet := isFlagSet("s")
 
 	// Применяем JSON конфиг только если флаг НЕ был установлен явно
@@ -120,12 +256,120 @@ func ApplyJSONConfig(jsonConfig *JSONConfig, flagsParsed bool) {
 	if !fileToWriteSet {
 		Options.FileToWrite = jsonConfig.FileStoragePath
 	}
+	if !fileCompactSizeSet {
+		Options.FileCompactSize = jsonConfig.FileCompactSize
+	}
+	if !fileCompactRatioSet {
+		Options.FileCompactRatio = jsonConfig.FileCompactRatio
+	}
 	if !dsnSet {
 		Options.DSN = jsonConfig.DatabaseDSN
 	}
+	if !sqlitePathSet {
+		Options.SQLitePath = jsonConfig.SQLitePath
+	}
+	if !boltPathSet {
+		Options.BoltPath = jsonConfig.BoltPath
+	}
+	if !idStrategySet {
+		Options.IDStrategy = jsonConfig.IDStrategy
+	}
+	if !idLengthSet {
+		Options.IDLength = jsonConfig.IDLength
+	}
+	if !idAlphabetSet {
+		Options.IDAlphabet = jsonConfig.IDAlphabet
+	}
+	if !reapIntervalSet {
+		errs = append(errs, parseDuration("reap_interval", jsonConfig.ReapInterval, &Options.ReapInterval))
+	}
+	if !cacheSizeSet {
+		Options.CacheSize = jsonConfig.CacheSize
+	}
+	if !cacheTTLSet {
+		errs = append(errs, parseDuration("cache_ttl", jsonConfig.CacheTTL, &Options.CacheTTL))
+	}
+	if !cacheNegTTLSet {
+		errs = append(errs, parseDuration("cache_negative_ttl", jsonConfig.CacheNegTTL, &Options.CacheNegTTL))
+	}
+	if !replicaDSNsSet {
+		Options.ReplicaDSNs = jsonConfig.ReplicaDSNs
+	}
+	if !readWindowSet {
+		errs = append(errs, parseDuration("read_after_write_window", jsonConfig.ReadWindow, &Options.ReadWindow))
+	}
+	if !readTimeoutSet {
+		errs = append(errs, parseDuration("db_read_timeout", jsonConfig.ReadTimeout, &Options.ReadTimeout))
+	}
+	if !writeTimeoutSet {
+		errs = append(errs, parseDuration("db_write_timeout", jsonConfig.WriteTimeout, &Options.WriteTimeout))
+	}
+	if !dbPgxpoolSet {
+		Options.DBPgxpool = jsonConfig.DBPgxpool
+	}
+	if !dbMaxOpenSet {
+		Options.DBMaxOpenConns = jsonConfig.DBMaxOpenConns
+	}
+	if !dbMaxIdleSet {
+		Options.DBMaxIdleConns = jsonConfig.DBMaxIdleConns
+	}
+	if !dbLifetimeSet {
+		errs = append(errs, parseDuration("db_conn_max_lifetime", jsonConfig.DBConnMaxLifetime, &Options.DBConnMaxLifetime))
+	}
+	if !dbIdleTimeSet {
+		errs = append(errs, parseDuration("db_conn_max_idle_time", jsonConfig.DBConnMaxIdleTime, &Options.DBConnMaxIdleTime))
+	}
+	if !dbHealthCheckSet {
+		errs = append(errs, parseDuration("db_health_check_period", jsonConfig.DBHealthCheck, &Options.DBHealthCheck))
+	}
+	if !dbStmtCacheSet {
+		Options.DBStatementCache = jsonConfig.DBStatementCache
+	}
+	if !dbRetryBudgetSet {
+		errs = append(errs, parseDuration("db_retry_budget", jsonConfig.DBRetryBudget, &Options.DBRetryBudget))
+	}
+	if !allowedSchemesSet {
+		Options.AllowedSchemes = jsonConfig.AllowedSchemes
+	}
+	if !stripTrackingSet {
+		Options.StripTracking = jsonConfig.StripTracking
+	}
+	if !blocklistSet {
+		Options.BlocklistPath = jsonConfig.BlocklistPath
+	}
+	if !blocklistStatusSet {
+		Options.BlocklistStatus = jsonConfig.BlocklistStatus
+	}
+	if !selfURLsSet {
+		Options.SelfURLs = jsonConfig.SelfURLs
+	}
+	if !selfLinksSet {
+		Options.SelfLinks = jsonConfig.SelfLinks
+	}
+	if !maxLinkDepthSet {
+		Options.MaxLinkDepth = jsonConfig.MaxLinkDepth
+	}
+	if !forceInterstitialSet {
+		Options.ForceInterstitial = jsonConfig.ForceInterstitial
+	}
+	if !trustedDomainsSet {
+		Options.TrustedDomains = jsonConfig.TrustedDomains
+	}
 	if !enableHTTPSSet {
 		Options.EnableHTTPS = jsonConfig.EnableHTTPS
 	}
+
+	return errors.Join(errs...)
+}
+
+// parseDuration разбирает длительность поля name JSON конфига в dst
+func parseDuration(name, value string, dst *time.Duration) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*dst = duration
+	return nil
 }
 
 // getConfigFilePath возвращает путь к файлу конфигурации с учетом приоритетов
@@ -161,12 +405,212 @@ func init() {
 		"",
 		"File to write logs",
 	)
+	flag.Int64Var( // Порог размера для автоматической компакции файлового хранилища
+		&Options.FileCompactSize,
+		"file-compact-min-size",
+		1<<20,
+		"File storage size in bytes below which it is never compacted automatically",
+	)
+	flag.Float64Var( // Порог доли мусора для автоматической компакции файлового хранилища
+		&Options.FileCompactRatio,
+		"file-compact-garbage-ratio",
+		0.5,
+		"Share of stale file storage lines that triggers compaction, 0 compacts only on SIGHUP",
+	)
 	flag.StringVar( // DSN к БД
 		&Options.DSN,
 		"d",
 		"",
 		"Database connection string",
 	)
+	flag.StringVar( // Путь к файлу SQLite
+		&Options.SQLitePath,
+		"sqlite-path",
+		"",
+		"SQLite database file",
+	)
+	flag.StringVar( // Путь к файлу bbolt
+		&Options.BoltPath,
+		"bolt-path",
+		"",
+		"bbolt database file",
+	)
+	flag.StringVar( // Стратегия генерации коротких идентификаторов
+		&Options.IDStrategy,
+		"id-strategy",
+		"random",
+		"Short ID generator: random, sequence or hash",
+	)
+	flag.IntVar( // Начальная длина короткого идентификатора
+		&Options.IDLength,
+		"id-length",
+		8,
+		"Initial short ID length, grows automatically on collisions",
+	)
+	flag.StringVar( // Алфавит короткого идентификатора (по умолчанию base62)
+		&Options.IDAlphabet,
+		"id-alphabet",
+		"",
+		"Short ID alphabet, base62 by default",
+	)
+	flag.DurationVar( // Период очистки истекших ссылок
+		&Options.ReapInterval,
+		"reap-interval",
+		time.Minute,
+		"How often expired URLs are reaped, 0 disables reaping",
+	)
+	flag.IntVar( // Размер кэша редиректов
+		&Options.CacheSize,
+		"cache-size",
+		10000,
+		"Redirect lookup cache size for database storages, 0 disables caching",
+	)
+	flag.DurationVar( // Время жизни записи в кэше
+		&Options.CacheTTL,
+		"cache-ttl",
+		time.Minute,
+		"How long a resolved URL stays in the redirect cache",
+	)
+	flag.DurationVar( // Время жизни отрицательного результата в кэше
+		&Options.CacheNegTTL,
+		"cache-negative-ttl",
+		5*time.Second,
+		"How long an unknown short ID stays in the redirect cache, 0 disables negative caching",
+	)
+	flag.Func( // DSN реплик для чтения, через запятую
+		"replica-dsn",
+		"Comma-separated PostgreSQL read replica connection strings",
+		func(value string) error {
+			Options.ReplicaDSNs = SplitList(value)
+			return nil
+		},
+	)
+	flag.DurationVar( // Окно чтения с основной базы после записи
+		&Options.ReadWindow,
+		"read-after-write-window",
+		5*time.Second,
+		"How long reads of a user's data go to the primary after the user writes",
+	)
+	flag.DurationVar( // Таймаут чтения из хранилища
+		&Options.ReadTimeout,
+		"db-read-timeout",
+		2*time.Second,
+		"Timeout for a single storage read, 0 disables it",
+	)
+	flag.DurationVar( // Таймаут записи в хранилище
+		&Options.WriteTimeout,
+		"db-write-timeout",
+		5*time.Second,
+		"Timeout for a single storage write, 0 disables it",
+	)
+	flag.BoolVar( // Пул pgxpool
+		&Options.DBPgxpool,
+		"db-pgxpool",
+		false,
+		"Manage PostgreSQL connections with pgxpool instead of database/sql",
+	)
+	flag.IntVar( // Максимум открытых соединений
+		&Options.DBMaxOpenConns,
+		"db-max-open-conns",
+		0,
+		"Maximum open PostgreSQL connections, 0 keeps the driver default",
+	)
+	flag.IntVar( // Максимум простаивающих соединений
+		&Options.DBMaxIdleConns,
+		"db-max-idle-conns",
+		2,
+		"Maximum idle PostgreSQL connections (minimum kept open with pgxpool)",
+	)
+	flag.DurationVar( // Время жизни соединения
+		&Options.DBConnMaxLifetime,
+		"db-conn-max-lifetime",
+		0,
+		"Maximum PostgreSQL connection lifetime, 0 keeps the driver default",
+	)
+	flag.DurationVar( // Время простоя соединения
+		&Options.DBConnMaxIdleTime,
+		"db-conn-max-idle-time",
+		0,
+		"How long a PostgreSQL connection may stay idle, 0 keeps the driver default",
+	)
+	flag.DurationVar( // Период проверки соединений
+		&Options.DBHealthCheck,
+		"db-health-check-period",
+		time.Minute,
+		"How often pgxpool checks idle connections",
+	)
+	flag.IntVar( // Кэш подготовленных выражений
+		&Options.DBStatementCache,
+		"db-statement-cache",
+		512,
+		"Prepared statement cache size per connection, 0 disables caching (e.g. behind pgbouncer)",
+	)
+	flag.DurationVar( // Бюджет повторов при временных ошибках
+		&Options.DBRetryBudget,
+		"db-retry-budget",
+		2*time.Second,
+		"Time budget for retrying a PostgreSQL operation after a transient error, 0 disables retries",
+	)
+	flag.Func( // Разрешенные схемы URL, через запятую
+		"allowed-schemes",
+		"Comma-separated URL schemes allowed for shortening (default http,https)",
+		func(value string) error {
+			Options.AllowedSchemes = SplitList(value)
+			return nil
+		},
+	)
+	flag.BoolVar( // Удаление трекинговых параметров
+		&Options.StripTracking,
+		"strip-tracking-params",
+		false,
+		"Remove utm_* query parameters from shortened URLs",
+	)
+	flag.StringVar( // Список запрещенных доменов
+		&Options.BlocklistPath,
+		"blocklist",
+		"",
+		"File with blocked hosts, wildcard domains, /regexps/ and CIDRs, reloaded on SIGHUP or change",
+	)
+	flag.IntVar( // Ответ на переход по ссылке на запрещенный домен
+		&Options.BlocklistStatus,
+		"blocklist-status",
+		451,
+		"Status for redirects to blocked hosts: 451 or 410",
+	)
+	flag.Func( // Другие адреса сервиса, через запятую
+		"self-urls",
+		"Comma-separated base URLs the service is also reachable at, besides -b",
+		func(value string) error {
+			Options.SelfURLs = SplitList(value)
+			return nil
+		},
+	)
+	flag.StringVar( // Ссылки на сам сервис
+		&Options.SelfLinks,
+		"self-links",
+		"resolve",
+		"What to do with URLs pointing back to the service: resolve (store the final URL of the chain) or reject",
+	)
+	flag.IntVar( // Длина цепочки коротких ссылок
+		&Options.MaxLinkDepth,
+		"max-link-depth",
+		5,
+		"Maximum number of short links followed when resolving a link to the service",
+	)
+	flag.BoolVar( // Промежуточная страница для всех ссылок
+		&Options.ForceInterstitial,
+		"force-interstitial",
+		false,
+		"Show an interstitial page instead of redirecting to external domains",
+	)
+	flag.Func( // Домены без промежуточной страницы, через запятую
+		"trusted-domains",
+		"Comma-separated domains (with subdomains) redirected to directly even with -force-interstitial",
+		func(value string) error {
+			Options.TrustedDomains = SplitList(value)
+			return nil
+		},
+	)
 	flag.StringVar( // Ключ для подписи куки
 		&Options.SecretKey,
 		"k",
@@ -193,6 +637,17 @@ func init() {
 	)
 }
 
+// SplitList разбирает список значений через запятую, пропуская пустые
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // isFlagSet проверяет, был ли установлен флаг с указанным именем
 func isFlagSet(name string) bool {
 	isSet := false
@@ -215,6 +670,25 @@ func CreateStorageConfig() {
 		return
 	}
 
+	// Встроенная SQLite, если внешней СУБД нет
+	if Options.SQLitePath != "" {
+		Config = StorageConfig{
+			StorageType: StorageSQLite,
+			FilePath:    Options.SQLitePath,
+		}
+		return
+	}
+
+	// Встроенная key-value база. Если одновременно задан файл-хранилище, он используется для первичного импорта
+	if Options.BoltPath != "" {
+		Config = StorageConfig{
+			StorageType: StorageBolt,
+			FilePath:    Options.BoltPath,
+			ImportPath:  Options.FileToWrite,
+		}
+		return
+	}
+
 	// Fallback до StorageFile в случае, если СУБД не обнаружено
 	if Options.FileToWrite != "" {
 		Config = StorageConfig{
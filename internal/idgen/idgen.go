@@ -0,0 +1,211 @@
+// Package idgen содержит стратегии генерации коротких идентификаторов ссылок
+package idgen
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Base62 алфавит по умолчанию: цифры и латиница в обоих регистрах
+const Base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// AttemptsPerLength количество коллизий подряд на одной длине, после которого генератор удлиняет идентификатор
+const AttemptsPerLength = 3
+
+// MaxLength максимальная начальная длина идентификатора. Колонка short_url в PostgreSQL вмещает 64 символа,
+// остаток оставлен под автоматическое удлинение
+const MaxLength = 32
+
+// Стратегии генерации
+const (
+	StrategyRandom   = "random"   // Случайный идентификатор, при коллизии генерируется новый
+	StrategySequence = "sequence" // Монотонный счетчик в выбранном алфавите
+	StrategyHash     = "hash"     // Детерминированный хеш от (пользователь, URL)
+)
+
+// Generator стратегия генерации коротких идентификаторов. Хранилище само проверяет занятость
+// идентификатора и при коллизии вызывает Generate повторно с увеличенным attempt
+type Generator interface {
+	// Generate возвращает кандидата для URL пользователя. attempt - номер попытки, начиная с 0
+	Generate(userID, originalURL string, attempt int) string
+}
+
+// New создает генератор по названию стратегии
+func New(strategy string, length int, alphabet string) (Generator, error) {
+	if alphabet == "" {
+		alphabet = Base62
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length < 1 || length > MaxLength {
+		return nil, fmt.Errorf("id length must be between 1 and %d, got %d", MaxLength, length)
+	}
+
+	switch strategy {
+	case StrategyRandom, "":
+		return NewRandom(length, alphabet), nil
+	case StrategySequence:
+		return NewSequence(length, alphabet), nil
+	case StrategyHash:
+		return NewHash(length, alphabet), nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy %q", strategy)
+	}
+}
+
+// validateAlphabet алфавит должен состоять минимум из двух различных символов, безопасных для пути URL
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return fmt.Errorf("alphabet must contain at least 2 characters")
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if !strings.ContainsRune(Base62+"-_", r) {
+			return fmt.Errorf("alphabet character %q is not URL-safe", r)
+		}
+		if seen[r] {
+			return fmt.Errorf("alphabet character %q is duplicated", r)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+// growingLength общая логика удлинения: если на текущей длине попытки заканчиваются коллизиями,
+// пространство идентификаторов заполняется, и длина увеличивается насовсем.
+// Серия коллизий удлиняет идентификатор на символ каждые AttemptsPerLength попыток, но сохраненную длину
+// увеличивает только один раз, при достижении порога: следующий новый идентификатор длиннее на один символ
+type growingLength struct {
+	length atomic.Int64
+}
+
+func (g *growingLength) forAttempt(attempt int) int {
+	steps := attempt / AttemptsPerLength
+	if steps == 0 {
+		return int(g.length.Load())
+	}
+
+	if attempt == AttemptsPerLength {
+		base := g.length.Load()
+		if g.length.CompareAndSwap(base, base+1) {
+			return int(base) + 1
+		}
+		// Проигравший гонку просто использует уже увеличенную длину
+	}
+	// Сохраненная длина уже увеличена этой серией на первом пороге, дальше длина растет только внутри серии
+	return int(g.length.Load()) + steps - 1
+}
+
+// Random случайные идентификаторы из crypto/rand
+type Random struct {
+	growingLength
+	alphabet string
+}
+
+// NewRandom создает генератор случайных идентификаторов
+func NewRandom(length int, alphabet string) *Random {
+	g := &Random{alphabet: alphabet}
+	g.length.Store(int64(length))
+	return g
+}
+
+// Generate возвращает случайный идентификатор. Пользователь и URL не учитываются
+func (g *Random) Generate(_, _ string, attempt int) string {
+	length := g.forAttempt(attempt)
+	size := big.NewInt(int64(len(g.alphabet)))
+
+	var sb strings.Builder
+	sb.Grow(length)
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			panic(fmt.Sprintf("crypto/rand failed: %v", err)) // crypto/rand не возвращает ошибок на поддерживаемых ОС
+		}
+		sb.WriteByte(g.alphabet[n.Int64()])
+	}
+	return sb.String()
+}
+
+// Sequence монотонный счетчик, закодированный в алфавите. Счетчик стартует с текущего времени в миллисекундах,
+// поэтому после перезапуска не пересекается с уже выданными идентификаторами (если выдача не опережала часы)
+type Sequence struct {
+	counter   atomic.Uint64
+	minLength int
+	alphabet  string
+}
+
+// NewSequence создает генератор на счетчике. minLength - минимальная длина, короткие значения дополняются слева
+func NewSequence(minLength int, alphabet string) *Sequence {
+	g := &Sequence{minLength: minLength, alphabet: alphabet}
+	g.counter.Store(uint64(time.Now().UnixMilli()))
+	return g
+}
+
+// Generate возвращает следующее значение счетчика. При коллизии следующая попытка просто берет следующее значение
+func (g *Sequence) Generate(_, _ string, _ int) string {
+	id := encode(g.counter.Add(1), g.alphabet)
+	if pad := g.minLength - len(id); pad > 0 {
+		id = strings.Repeat(g.alphabet[:1], pad) + id
+	}
+	return id
+}
+
+// Hash детерминированный идентификатор от (пользователь, URL): повторное сокращение дает тот же результат
+type Hash struct {
+	growingLength
+	alphabet string
+}
+
+// NewHash создает генератор на хешах
+func NewHash(length int, alphabet string) *Hash {
+	g := &Hash{alphabet: alphabet}
+	g.length.Store(int64(length))
+	return g
+}
+
+// Generate возвращает префикс SHA-256 от пользователя, URL и номера попытки, закодированный в алфавите
+func (g *Hash) Generate(userID, originalURL string, attempt int) string {
+	length := g.forAttempt(attempt)
+
+	h := sha256.New()
+	h.Write([]byte(userID))
+	h.Write([]byte{0}) // Разделитель, чтобы ("ab", "c") и ("a", "bc") давали разные хеши
+	h.Write([]byte(originalURL))
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(attempt)))
+	sum := h.Sum(nil)
+
+	var sb strings.Builder
+	sb.Grow(length)
+	n := new(big.Int).SetBytes(sum)
+	base := big.NewInt(int64(len(g.alphabet)))
+	mod := new(big.Int)
+	for i := 0; i < length; i++ {
+		n.DivMod(n, base, mod)
+		sb.WriteByte(g.alphabet[mod.Int64()])
+	}
+	return sb.String()
+}
+
+// encode переводит число в систему счисления с заданным алфавитом
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
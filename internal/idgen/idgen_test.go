@@ -0,0 +1,79 @@
+package idgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		length   int
+		alphabet string
+	}{
+		{name: "Unknown strategy", strategy: "uuid", length: 8},
+		{name: "Zero length", strategy: StrategyRandom, length: 0},
+		{name: "Too long", strategy: StrategyRandom, length: MaxLength + 1},
+		{name: "Single character alphabet", strategy: StrategyRandom, length: 8, alphabet: "a"},
+		{name: "Duplicated character", strategy: StrategyRandom, length: 8, alphabet: "abca"},
+		{name: "Not URL-safe character", strategy: StrategyRandom, length: 8, alphabet: "ab/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.strategy, tt.length, tt.alphabet)
+			assert.Error(t, err)
+		})
+	}
+
+	g, err := New("", 8, "")
+	require.NoError(t, err)
+	assert.IsType(t, &Random{}, g)
+}
+
+// TestRandomGrowsLength проверяет, что после серии коллизий длина увеличивается и остается увеличенной
+func TestRandomGrowsLength(t *testing.T) {
+	g := NewRandom(4, "ab")
+
+	for attempt := 0; attempt < AttemptsPerLength; attempt++ {
+		id := g.Generate("", "", attempt)
+		assert.Len(t, id, 4)
+		assert.Empty(t, strings.Trim(id, "ab"))
+	}
+
+	// Внутри серии длина растет на символ каждые AttemptsPerLength попыток
+	for attempt := AttemptsPerLength; attempt < 4*AttemptsPerLength; attempt++ {
+		assert.Len(t, g.Generate("", "", attempt), 4+attempt/AttemptsPerLength, "attempt %d", attempt)
+	}
+
+	// Сохраненная длина увеличивается на один символ за серию
+	assert.Len(t, g.Generate("", "", 0), 5, "length growth is permanent")
+	assert.Len(t, g.Generate("", "", AttemptsPerLength), 6)
+	assert.Len(t, g.Generate("", "", 0), 6)
+}
+
+func TestSequence(t *testing.T) {
+	g := NewSequence(12, Base62)
+
+	first := g.Generate("", "", 0)
+	second := g.Generate("", "", 0)
+	assert.Len(t, first, 12)
+	assert.NotEqual(t, first, second)
+	assert.Less(t, first, second, "same-length base62 ids sort like numbers")
+
+	assert.Equal(t, "0", encode(0, Base62))
+	assert.Equal(t, "10", encode(62, Base62))
+}
+
+func TestHashDeterministic(t *testing.T) {
+	g := NewHash(8, Base62)
+
+	id := g.Generate("user", "https://example.com", 0)
+	assert.Len(t, id, 8)
+	assert.Equal(t, id, NewHash(8, Base62).Generate("user", "https://example.com", 0))
+	assert.NotEqual(t, id, g.Generate("other", "https://example.com", 0))
+	assert.NotEqual(t, id, g.Generate("user", "https://example.com", 1), "retry must yield a new candidate")
+}
@@ -0,0 +1,94 @@
+package qrcode
+
+// Веса правил штрафа при выборе маски
+const (
+	penaltyRun     = 3  // Полоса из 5 модулей одного цвета, дальше +1 за каждый модуль
+	penaltyBlock   = 3  // Квадрат 2x2 одного цвета
+	penaltyFinder  = 40 // Узор 1:1:3:1:1, похожий на поисковый
+	penaltyBalance = 10 // Каждые 5% отклонения доли темных модулей от половины
+)
+
+// penalty штраф символа с текущей маской: чем меньше, тем легче сканерам его читать
+func (c *Code) penalty() int {
+	result := 0
+
+	for y := 0; y < c.Size; y++ {
+		result += c.linePenalty(func(i int) bool { return c.modules[y][i] })
+	}
+	for x := 0; x < c.Size; x++ {
+		result += c.linePenalty(func(i int) bool { return c.modules[i][x] })
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			color := c.modules[y][x]
+			if color {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size &&
+				color == c.modules[y][x+1] && color == c.modules[y+1][x] && color == c.modules[y+1][x+1] {
+				result += penaltyBlock
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*penaltyBalance
+}
+
+// linePenalty штраф за полосы и ложные поисковые узоры в одной строке или столбце
+func (c *Code) linePenalty(module func(i int) bool) int {
+	result := 0
+	runColor, runLength := false, 0
+	var history [7]int
+
+	for i := 0; i < c.Size; i++ {
+		if module(i) == runColor {
+			runLength++
+			if runLength == 5 {
+				result += penaltyRun
+			} else if runLength > 5 {
+				result++
+			}
+			continue
+		}
+		c.addRun(runLength, &history)
+		if !runColor {
+			result += countFinderLike(history) * penaltyFinder
+		}
+		runColor, runLength = module(i), 1
+	}
+
+	// За краем символа светлое поле
+	if runColor {
+		c.addRun(runLength, &history)
+		runLength = 0
+	}
+	c.addRun(runLength+c.Size, &history)
+	return result + countFinderLike(history)*penaltyFinder
+}
+
+// addRun добавляет длину полосы в историю последних семи полос. Первая полоса продолжает светлое поле за краем
+func (c *Code) addRun(length int, history *[7]int) {
+	if history[0] == 0 {
+		length += c.Size
+	}
+	copy(history[1:], history[:6])
+	history[0] = length
+}
+
+// countFinderLike сколько узоров 1:1:3:1:1 со светлым полем в 4 модуля с одной из сторон заканчиваются в истории
+func countFinderLike(history [7]int) int {
+	n := history[1]
+	core := n > 0 && history[2] == n && history[3] == n*3 && history[4] == n && history[5] == n
+	count := 0
+	if core && history[0] >= n*4 && history[6] >= n {
+		count++
+	}
+	if core && history[6] >= n*4 && history[0] >= n {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,241 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRSRemainder сверяет коды коррекции с примером "HELLO WORLD" 1-M из стандарта
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, rsRemainder(data, rsDivisor(len(want))))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	// Маска 0 для каждого уровня, значения из таблицы стандарта
+	for level, want := range map[Level]string{
+		Low:      "111011111000100",
+		Medium:   "101010000010010",
+		Quartile: "011010101011111",
+		High:     "001011010001001",
+	} {
+		c := &Code{Version: 1, Level: level, Size: 21, modules: newGrid(21), function: newGrid(21)}
+		c.drawFormatBits(0)
+		assert.Equal(t, want, readBits(c, formatPositions(c)), level.String())
+	}
+
+	c := &Code{Version: 7, Level: Low, Size: 45, modules: newGrid(45), function: newGrid(45)}
+	c.drawVersion()
+	var got strings.Builder
+	for i := 17; i >= 0; i-- {
+		got.WriteString(map[bool]string{false: "0", true: "1"}[c.Black(c.Size-11+i%3, i/3)])
+	}
+	assert.Equal(t, "000111110010010100", got.String())
+}
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		version int
+		level   Level
+		bytes   int
+	}{
+		{version: 1, level: Low, bytes: 17},
+		{version: 1, level: High, bytes: 7},
+		{version: 10, level: Medium, bytes: 213},
+		{version: 40, level: Low, bytes: 2953},
+		{version: 40, level: High, bytes: 1273},
+	}
+	for _, tt := range tests {
+		fits, err := Encode(bytes.Repeat([]byte("a"), tt.bytes), tt.level)
+		require.NoError(t, err)
+		assert.Equal(t, tt.version, fits.Version)
+
+		if tt.version < MaxVersion {
+			bigger, err := Encode(bytes.Repeat([]byte("a"), tt.bytes+1), tt.level)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version+1, bigger.Version)
+		} else {
+			_, err = Encode(bytes.Repeat([]byte("a"), tt.bytes+1), tt.level)
+			assert.ErrorIs(t, err, ErrTooLong)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	assert.Empty(t, alignmentPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32))
+	assert.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPositions(40))
+}
+
+// TestRoundTrip читает данные обратно из построенного символа: формат, снятие маски, обход модулей,
+// разбор блоков с проверкой кодов коррекции
+func TestRoundTrip(t *testing.T) {
+	for _, data := range []string{"", "https://short.example/abc123", strings.Repeat("Пример длинной ссылки ", 30)} {
+		for level := Low; level <= High; level++ {
+			c, err := Encode([]byte(data), level)
+			require.NoError(t, err)
+			assert.Equal(t, data, decode(t, c), "%s %d bytes", level, len(data))
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode([]byte("https://short.example/abc123"), Medium)
+	require.NoError(t, err)
+	modules := c.Size + 2*DefaultMargin
+
+	img := c.Image(300, DefaultMargin)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	scale := 300 / modules
+	offset := (300-modules*scale)/2 + DefaultMargin*scale
+	assert.Equal(t, uint8(0), img.ColorIndexAt(0, 0))
+	assert.Equal(t, uint8(1), img.ColorIndexAt(offset, offset)) // Угол поискового узора
+	assert.Equal(t, uint8(0), img.ColorIndexAt(offset-1, offset))
+
+	// Слишком маленький размер не ломает символ
+	assert.Equal(t, modules, c.Image(10, DefaultMargin).Bounds().Dx())
+
+	var buf bytes.Buffer
+	require.NoError(t, c.PNG(&buf, 300, DefaultMargin))
+	decoded, err := png.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 300, decoded.Bounds().Dx())
+
+	buf.Reset()
+	require.NoError(t, c.SVG(&buf, 512, 2))
+	svg := buf.String()
+	assert.Contains(t, svg, `width="512" height="512"`)
+	assert.Contains(t, svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, c.Size+4, c.Size+4))
+	assert.Contains(t, svg, "M2,2h7v1h-7z") // Верхняя кромка поискового узора
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("q")
+	require.NoError(t, err)
+	assert.Equal(t, Quartile, level)
+
+	_, err = ParseLevel("X")
+	assert.Error(t, err)
+}
+
+// formatPositions координаты первой копии поля формата, от старшего бита к младшему
+func formatPositions(c *Code) [][2]int {
+	var positions [][2]int
+	for i := 14; i >= 9; i-- {
+		positions = append(positions, [2]int{14 - i, 8})
+	}
+	positions = append(positions, [2]int{7, 8}, [2]int{8, 8}, [2]int{8, 7})
+	for i := 5; i >= 0; i-- {
+		positions = append(positions, [2]int{8, i})
+	}
+	return positions
+}
+
+func readBits(c *Code, positions [][2]int) string {
+	var s strings.Builder
+	for _, p := range positions {
+		if c.Black(p[0], p[1]) {
+			s.WriteByte('1')
+		} else {
+			s.WriteByte('0')
+		}
+	}
+	return s.String()
+}
+
+// decode минимальный декодер байтового режима без исправления ошибок
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+
+	format := 0
+	for _, b := range readBits(c, formatPositions(c)) {
+		format = format<<1 | int(b-'0')
+	}
+	format ^= 0x5412
+	require.Equal(t, c.Level.formatBits(), format>>13, "level in format bits")
+	mask := format >> 10 & 7
+
+	// Служебные модули той же версии и снятие маски с копии символа
+	plain := &Code{Version: c.Version, Level: c.Level, Size: c.Size, modules: newGrid(c.Size), function: newGrid(c.Size)}
+	plain.drawFunctionPatterns()
+	for y := range c.modules {
+		copy(plain.modules[y], c.modules[y])
+	}
+	plain.applyMask(mask)
+
+	var raw bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if !plain.function[y][right-j] {
+					raw = append(raw, plain.modules[y][right-j])
+				}
+			}
+		}
+	}
+	codewords := raw.bytes()[:numRawDataModules(c.Version)/8]
+
+	// Обратное перемежение: сначала данные всех блоков, затем коды коррекции
+	numBlocks := numECCBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortData := len(codewords)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for j, block := range blocks {
+		ecc := codewords[k+j : k+j+1]
+		for i := 1; i < eccLen; i++ {
+			ecc = append(append([]byte{}, ecc...), codewords[k+i*numBlocks+j])
+		}
+		require.Equal(t, rsRemainder(block, rsDivisor(eccLen)), ecc, "ecc of block %d", j)
+		data = append(data, block...)
+	}
+
+	var bits bitBuffer
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	read := func(from, n int) int {
+		v := 0
+		for _, b := range bits[from : from+n] {
+			v <<= 1
+			if b {
+				v |= 1
+			}
+		}
+		return v
+	}
+	require.Equal(t, 0b0100, read(0, 4), "byte mode")
+	countBits := charCountBits(c.Version)
+	count := read(4, countBits)
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return string(result)
+}
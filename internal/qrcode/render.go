@@ -0,0 +1,72 @@
+package qrcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// DefaultMargin ширина светлого поля вокруг символа в модулях, которую требует стандарт
+const DefaultMargin = 4
+
+// Image рисует код с полем margin модулей на квадрате со стороной size пикселей. Модуль занимает целое число
+// пикселей, остаток стороны уходит в поле. Если size меньше символа, модуль рисуется одним пикселем
+func (c *Code) Image(size, margin int) *image.Paletted {
+	modules := c.Size + 2*margin
+	scale := max(1, size/modules)
+	side := max(size, modules*scale)
+	offset := (side-modules*scale)/2 + margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := offset + y*scale; py < offset+(y+1)*scale; py++ {
+				row := img.Pix[py*img.Stride:]
+				for px := offset + x*scale; px < offset+(x+1)*scale; px++ {
+					row[px] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNG пишет код в формате PNG, параметры как у Image
+func (c *Code) PNG(w io.Writer, size, margin int) error {
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(w, c.Image(size, margin))
+}
+
+// SVG пишет код в формате SVG шириной и высотой size. Координаты заданы в модулях, поэтому картинка масштабируется
+// без потери четкости. Темные модули одной строки склеиваются в прямоугольники одного пути
+func (c *Code) SVG(w io.Writer, size, margin int) error {
+	modules := c.Size + 2*margin
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n"+
+		`<rect width="%d" height="%d" fill="#fff"/>`+"\n"+`<path fill="#000" d="`,
+		size, size, modules, modules, modules, modules)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; {
+			if !c.modules[y][x] {
+				x++
+				continue
+			}
+			start := x
+			for x < c.Size && c.modules[y][x] {
+				x++
+			}
+			fmt.Fprintf(bw, "M%d,%dh%dv1h-%dz", start+margin, y+margin, x-start, x-start)
+		}
+	}
+	fmt.Fprint(bw, `"/>`+"\n</svg>\n")
+
+	return bw.Flush()
+}
@@ -0,0 +1,642 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/internal/config"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Бакеты bbolt
+var (
+	linksBucket       = []byte("links")        // shortID: JSON models.URLRecord
+	originalsBucket   = []byte("originals")    // originalURL: shortID живой ссылки, для 409 Conflict при повторном сокращении
+	usersBucket       = []byte("users")        // "user:"+userID: вложенный бакет shortID: пустое значение
+	metaBucket        = []byte("meta")         // служебные ключи
+	clicksBucket      = []byte("clicks")       // shortID: вложенный бакет дата: JSON models.DailyClicks
+	visitsBucket      = []byte("visits")       // shortID: вложенный бакет порядковый номер: JSON models.Visit
+	visitTotalsBucket = []byte("visit_totals") // shortID: JSON models.VisitStats из журнала, где отдельных событий нет
+	jobsBucket        = []byte("jobs")         // ID задачи: JSON models.DeletionJob
+	jobQueueBucket    = []byte("job_queue")    // порядковый номер: ID задачи в статусе queued
+
+	importedKey = []byte("imported_from") // Путь к JSONL файлу, из которого выполнен первичный импорт
+)
+
+// BoltStore хранилище ссылок во встроенной key-value базе bbolt. В отличие от FileStore не держит все ссылки в памяти
+type BoltStore struct {
+	DB *bolt.DB
+}
+
+// OpenBolt открывает базу bbolt по пути path. Если база пустая и задан importPath,
+// ссылки однократно импортируются из JSONL журнала файлового хранилища
+func OpenBolt(path, importPath string, logger zap.SugaredLogger) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second}) // Timeout на случай, если файл занят другим процессом
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть bbolt: %v", err)
+	}
+
+	s := &BoltStore{DB: db}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, originalsBucket, usersBucket, metaBucket, clicksBucket, visitsBucket,
+			visitTotalsBucket, jobsBucket, jobQueueBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	if importPath != "" {
+		if err = s.importJournal(importPath, logger); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// importJournal переносит из JSONL журнала ссылки вместе со счетчиками переходов, разбивкой посещений
+// и задачами на удаление, если база еще пустая и импорт не выполнялся
+func (s *BoltStore) importJournal(path string, logger zap.SugaredLogger) error {
+	needImport := false
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		needImport = tx.Bucket(metaBucket).Get(importedKey) == nil && tx.Bucket(linksBucket).Stats().KeyN == 0
+		return nil
+	})
+	if err != nil || !needImport {
+		return err
+	}
+
+	mem := NewMemoryStore()
+	if _, err = replayJournal(path, mem, logger); err != nil {
+		return fmt.Errorf("cannot read %s for import: %w", path, err)
+	}
+	records := mem.snapshot()
+
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		for _, record := range records {
+			if err := putLink(tx, record); err != nil {
+				return err
+			}
+			if err := addDailyClicks(tx, record.ShortURL, mem.dailyClicks(record.ShortURL)); err != nil {
+				return err
+			}
+			stats, _ := mem.VisitStats(context.Background(), record.ShortURL) // Память ошибок не возвращает
+			if stats.HumanVisits+stats.BotVisits > 0 {
+				if err := putJSON(tx.Bucket(visitTotalsBucket), record.ShortURL, stats); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Журнал не хранит статус running, незавершенные задачи приходят в статусе queued и снова встают в очередь
+		for _, job := range mem.jobSnapshot() {
+			save := saveJob
+			if job.Status == models.JobQueued {
+				save = queueJob
+			}
+			if err := save(tx, job); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(metaBucket).Put(importedKey, []byte(path))
+	})
+	if err != nil {
+		return fmt.Errorf("cannot import %s: %w", path, err)
+	}
+
+	logger.Infof("Imported %d URLs from %s into bbolt", len(records), path)
+	return nil
+}
+
+// Shorten сохраняет URL. При повторном сокращении того же URL возвращает существующий shortID и ErrConflict
+func (s *BoltStore) Shorten(_ context.Context, userID string, req models.ShortenRequest) (string, error) {
+	var shortID string
+	var conflict bool
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		shortID, conflict, err = insertLink(tx, userID, req)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if conflict {
+		return shortID, ErrConflict
+	}
+
+	return shortID, nil
+}
+
+// ShortenBatch сохраняет несколько URL в одной транзакции
+func (s *BoltStore) ShortenBatch(_ context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	results := make([]ShortenResult, 0, len(reqs))
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		for _, req := range reqs {
+			shortID, conflict, err := insertLink(tx, userID, req)
+			if err != nil {
+				return err
+			}
+			results = append(results, ShortenResult{ShortID: shortID, Conflict: conflict})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Resolve получает запись по сокращенному URL
+func (s *BoltStore) Resolve(_ context.Context, shortID string) (models.URLRecord, error) {
+	var record models.URLRecord
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getLink(tx, shortID)
+		return err
+	})
+
+	return record, err
+}
+
+// ListByUser получает все неудаленные ссылки пользователя
+func (s *BoltStore) ListByUser(_ context.Context, userID string) ([]models.URLResponse, error) {
+	var result []models.URLResponse
+	now := time.Now()
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		userLinks := tx.Bucket(usersBucket).Bucket(userBucketName(userID))
+		if userLinks == nil {
+			return nil
+		}
+
+		return userLinks.ForEach(func(k, _ []byte) error {
+			record, err := getLink(tx, string(k))
+			if err != nil {
+				return err
+			}
+			if record.DeletedFlag || record.Expired(now) {
+				return nil
+			}
+			result = append(result, models.URLResponse{
+				ShortURL:    config.Options.BaseAddress + "/" + record.ShortURL,
+				OriginalURL: record.OriginalURL,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Delete помечает ссылки пользователя удаленными. Чужие и несуществующие ссылки пропускаются
+func (s *BoltStore) Delete(_ context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	var result DeleteResult
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		targets, counts, err := classifyDeletion(userID, shortIDs, func(shortID string) (models.URLRecord, error) {
+			return getLink(tx, shortID)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, record := range targets {
+			if err = markLinkDeleted(tx, record); err != nil {
+				return err
+			}
+		}
+		result = counts
+		return nil
+	})
+	if err != nil {
+		return DeleteResult{}, err
+	}
+
+	return result, nil
+}
+
+// EnqueueDeletion сохраняет задачу на удаление и ставит ее в конец очереди
+func (s *BoltStore) EnqueueDeletion(_ context.Context, job models.DeletionJob) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return queueJob(tx, job)
+	})
+}
+
+// ClaimDeletion берет из очереди самую старую задачу
+func (s *BoltStore) ClaimDeletion(_ context.Context, now time.Time) (models.DeletionJob, error) {
+	var job models.DeletionJob
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		key, id := tx.Bucket(jobQueueBucket).Cursor().First()
+		if key == nil {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(jobQueueBucket).Delete(key); err != nil {
+			return err
+		}
+
+		var err error
+		if job, err = getJob(tx, string(id)); err != nil {
+			return err
+		}
+		job.Status = models.JobRunning
+		job.UpdatedAt = now
+		return saveJob(tx, job)
+	})
+	if err != nil {
+		return models.DeletionJob{}, err
+	}
+
+	return job, nil
+}
+
+// FinishDeletion сохраняет итоговый статус задачи
+func (s *BoltStore) FinishDeletion(_ context.Context, job models.DeletionJob) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return saveJob(tx, job)
+	})
+}
+
+// DeletionJob возвращает задачу на удаление по ID
+func (s *BoltStore) DeletionJob(_ context.Context, id string) (models.DeletionJob, error) {
+	var job models.DeletionJob
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		job, err = getJob(tx, id)
+		return err
+	})
+
+	return job, err
+}
+
+// RequeueDeletions возвращает в очередь задачи, прерванные остановкой сервера
+func (s *BoltStore) RequeueDeletions(_ context.Context) (int, error) {
+	requeued := 0
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		var running []models.DeletionJob
+		err := tx.Bucket(jobsBucket).ForEach(func(k, _ []byte) error {
+			job, err := getJob(tx, string(k))
+			if err != nil {
+				return err
+			}
+			if job.Status == models.JobRunning {
+				running = append(running, job)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Как и в ReapExpired, бакет меняется только после обхода
+		sort.Slice(running, func(i, j int) bool { return running[i].CreatedAt.Before(running[j].CreatedAt) })
+		for _, job := range running {
+			job.Status = models.JobQueued
+			if err = queueJob(tx, job); err != nil {
+				return err
+			}
+		}
+		requeued = len(running)
+		return nil
+	})
+
+	return requeued, err
+}
+
+// ReapExpired помечает истекшие ссылки удаленными. Бакета по сроку нет, поэтому просматриваются все ссылки
+func (s *BoltStore) ReapExpired(_ context.Context, now time.Time) (int, error) {
+	reaped := 0
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		var expired []models.URLRecord
+		err := tx.Bucket(linksBucket).ForEach(func(k, _ []byte) error {
+			record, err := getLink(tx, string(k))
+			if err != nil {
+				return err
+			}
+			if !record.DeletedFlag && record.Expired(now) {
+				expired = append(expired, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Менять бакет во время ForEach нельзя, поэтому сохраняем после обхода
+		for _, record := range expired {
+			if err = markLinkDeleted(tx, record); err != nil {
+				return err
+			}
+		}
+		reaped = len(expired)
+		return nil
+	})
+
+	return reaped, err
+}
+
+// RecordClicks добавляет прирост дневных счетчиков переходов в одной транзакции
+func (s *BoltStore) RecordClicks(_ context.Context, clicks []Click) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		for shortID, daily := range aggregateClicks(clicks) {
+			if tx.Bucket(linksBucket).Get([]byte(shortID)) == nil {
+				continue // Ссылки нет, счетчики не сохраняем
+			}
+			if err := addDailyClicks(tx, shortID, daily); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClickStats возвращает статистику переходов по ссылке. Ключи бакета - даты, bbolt обходит их по возрастанию
+func (s *BoltStore) ClickStats(_ context.Context, shortID string) (models.LinkStats, error) {
+	var daily []models.DailyClicks
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		days := tx.Bucket(clicksBucket).Bucket([]byte(shortID))
+		if days == nil {
+			return nil
+		}
+		return days.ForEach(func(_, v []byte) error {
+			var day models.DailyClicks
+			if err := json.Unmarshal(v, &day); err != nil {
+				return err
+			}
+			daily = append(daily, day)
+			return nil
+		})
+	})
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+
+	return buildStats(shortID, daily), nil
+}
+
+// RecordVisits сохраняет события перехода в одной транзакции
+func (s *BoltStore) RecordVisits(_ context.Context, visits []models.Visit) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		for _, visit := range visits {
+			if tx.Bucket(linksBucket).Get([]byte(visit.ShortURL)) == nil {
+				continue
+			}
+
+			events, err := tx.Bucket(visitsBucket).CreateBucketIfNotExists([]byte(visit.ShortURL))
+			if err != nil {
+				return err
+			}
+			seq, err := events.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(visit)
+			if err != nil {
+				return err
+			}
+			if err = events.Put(binary.BigEndian.AppendUint64(nil, seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// VisitStats считает разбивку посещений ссылки обходом ее событий поверх разбивки, импортированной из журнала
+func (s *BoltStore) VisitStats(_ context.Context, shortID string) (models.VisitStats, error) {
+	stats := newVisitStats(shortID)
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(visitTotalsBucket).Get([]byte(shortID)); data != nil {
+			var imported models.VisitStats
+			if err := json.Unmarshal(data, &imported); err != nil {
+				return fmt.Errorf("corrupted visit totals %s: %w", shortID, err)
+			}
+			mergeVisitStats(&stats, imported)
+		}
+
+		events := tx.Bucket(visitsBucket).Bucket([]byte(shortID))
+		if events == nil {
+			return nil
+		}
+		return events.ForEach(func(_, v []byte) error {
+			var visit models.Visit
+			if err := json.Unmarshal(v, &visit); err != nil {
+				return err
+			}
+			addVisit(&stats, visit)
+			return nil
+		})
+	})
+	if err != nil {
+		return models.VisitStats{}, err
+	}
+
+	return stats, nil
+}
+
+// Ping проверяет, что база открыта
+func (s *BoltStore) Ping(_ context.Context) error {
+	return s.DB.View(func(*bolt.Tx) error { return nil })
+}
+
+// Close закрывает базу
+func (s *BoltStore) Close() error {
+	return s.DB.Close()
+}
+
+// insertLink создает ссылку либо возвращает существующую живую для того же URL
+func insertLink(tx *bolt.Tx, userID string, req models.ShortenRequest) (string, bool, error) {
+	if existing, ok := liveOriginal(tx, req.URL); ok {
+		return existing, true, nil
+	}
+
+	// Запись идет в транзакции Update, а она в bbolt единственная, поэтому проверка занятости не гоняется со вставкой
+	shortID, err := generateID(userID, req, func(shortID string) (string, error) {
+		if tx.Bucket(linksBucket).Get([]byte(shortID)) != nil {
+			return "", errIDTaken
+		}
+
+		record := newLinkRecord(userID, shortID, req)
+		return shortID, putLink(tx, record)
+	})
+
+	return shortID, false, err
+}
+
+// putLink сохраняет запись вместе с индексами по оригинальному URL и пользователю.
+// Удаленная запись в индекс оригиналов не попадает, чтобы повторное сокращение URL создало новую ссылку
+func putLink(tx *bolt.Tx, record models.URLRecord) error {
+	if err := saveLink(tx, record); err != nil {
+		return err
+	}
+
+	if _, ok := liveOriginal(tx, record.OriginalURL); !ok && !record.DeletedFlag {
+		if err := tx.Bucket(originalsBucket).Put([]byte(record.OriginalURL), []byte(record.ShortURL)); err != nil {
+			return err
+		}
+	}
+
+	userLinks, err := tx.Bucket(usersBucket).CreateBucketIfNotExists(userBucketName(record.UUID))
+	if err != nil {
+		return err
+	}
+	return userLinks.Put([]byte(record.ShortURL), []byte{})
+}
+
+// liveOriginal возвращает shortID из индекса оригиналов, если он указывает на неудаленную ссылку.
+// Базы, созданные до очистки индекса при удалении, еще могут хранить в нем удаленные ссылки
+func liveOriginal(tx *bolt.Tx, originalURL string) (string, bool) {
+	shortID := tx.Bucket(originalsBucket).Get([]byte(originalURL))
+	if shortID == nil {
+		return "", false
+	}
+	record, err := getLink(tx, string(shortID))
+	if err != nil || record.DeletedFlag {
+		return "", false
+	}
+	return string(shortID), true
+}
+
+// markLinkDeleted помечает запись удаленной и убирает ее из индекса оригиналов
+func markLinkDeleted(tx *bolt.Tx, record models.URLRecord) error {
+	record.DeletedFlag = true
+	if err := saveLink(tx, record); err != nil {
+		return err
+	}
+
+	originals := tx.Bucket(originalsBucket)
+	if string(originals.Get([]byte(record.OriginalURL))) == record.ShortURL {
+		return originals.Delete([]byte(record.OriginalURL))
+	}
+	return nil
+}
+
+// addDailyClicks прибавляет дневные счетчики к сохраненным счетчикам ссылки
+func addDailyClicks(tx *bolt.Tx, shortID string, daily []models.DailyClicks) error {
+	if len(daily) == 0 {
+		return nil
+	}
+
+	days, err := tx.Bucket(clicksBucket).CreateBucketIfNotExists([]byte(shortID))
+	if err != nil {
+		return err
+	}
+	for _, day := range daily {
+		if data := days.Get([]byte(day.Date)); data != nil {
+			var stored models.DailyClicks
+			if err = json.Unmarshal(data, &stored); err != nil {
+				return fmt.Errorf("corrupted clicks %s/%s: %w", shortID, day.Date, err)
+			}
+			day = mergeDaily(stored, day)
+		}
+		if err = putJSON(days, day.Date, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putJSON сохраняет значение в бакет в виде JSON
+func putJSON(bucket *bolt.Bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
+
+// saveLink перезаписывает саму запись, не трогая индексы
+func saveLink(tx *bolt.Tx, record models.URLRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(linksBucket).Put([]byte(record.ShortURL), data)
+}
+
+// getLink читает запись по shortID либо возвращает ErrNotFound
+func getLink(tx *bolt.Tx, shortID string) (models.URLRecord, error) {
+	var record models.URLRecord
+
+	data := tx.Bucket(linksBucket).Get([]byte(shortID))
+	if data == nil {
+		return record, ErrNotFound
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("corrupted record %s: %w", shortID, err)
+	}
+
+	return record, nil
+}
+
+// queueJob сохраняет задачу и добавляет ее в конец очереди
+func queueJob(tx *bolt.Tx, job models.DeletionJob) error {
+	if err := saveJob(tx, job); err != nil {
+		return err
+	}
+
+	queue := tx.Bucket(jobQueueBucket)
+	seq, err := queue.NextSequence()
+	if err != nil {
+		return err
+	}
+	return queue.Put(binary.BigEndian.AppendUint64(nil, seq), []byte(job.ID))
+}
+
+// saveJob перезаписывает задачу, не трогая очередь
+func saveJob(tx *bolt.Tx, job models.DeletionJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(jobsBucket).Put([]byte(job.ID), data)
+}
+
+// getJob читает задачу по ID либо возвращает ErrNotFound
+func getJob(tx *bolt.Tx, id string) (models.DeletionJob, error) {
+	var job models.DeletionJob
+
+	data := tx.Bucket(jobsBucket).Get([]byte(id))
+	if data == nil {
+		return job, ErrNotFound
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, fmt.Errorf("corrupted deletion job %s: %w", id, err)
+	}
+
+	return job, nil
+}
+
+// userBucketName имя вложенного бакета пользователя. Префикс нужен, потому что bbolt не допускает пустое имя бакета,
+// а ссылки, созданные без аутентификации, хранятся с пустым userID
+func userBucketName(userID string) []byte {
+	return []byte("user:" + userID)
+}
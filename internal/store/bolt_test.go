@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// TestBoltStoreImport проверяет первичный импорт JSONL журнала со статистикой и задачами и дедупликацию по оригинальному URL
+func TestBoltStoreImport(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	dir := t.TempDir()
+
+	// Готовим журнал файлового хранилища с живой и удаленной ссылкой
+	fileStore, err := OpenFileStore(filepath.Join(dir, "urls.jsonl"), sugar)
+	require.NoError(t, err)
+	live, err := fileStore.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/live"})
+	require.NoError(t, err)
+	deleted, err := fileStore.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/deleted"})
+	require.NoError(t, err)
+	_, err = fileStore.Delete(ctx, "user", []string{deleted})
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, fileStore.RecordClicks(ctx, []Click{{ShortID: live, At: now}, {ShortID: live, At: now}}))
+	require.NoError(t, fileStore.RecordVisits(ctx, []models.Visit{{ShortURL: live, At: now, Browser: "Firefox", OS: "Linux", Device: "desktop"}}))
+	queued := models.DeletionJob{ID: "queued", UserID: "user", ShortIDs: []string{live}, Status: models.JobQueued, CreatedAt: now}
+	require.NoError(t, fileStore.EnqueueDeletion(ctx, queued))
+	done := models.DeletionJob{ID: "done", UserID: "user", Status: models.JobDone, CreatedAt: now}
+	require.NoError(t, fileStore.FinishDeletion(ctx, done))
+
+	s, err := OpenBolt(filepath.Join(dir, "urls.bolt"), fileStore.Path(), sugar)
+	require.NoError(t, err)
+
+	// Статистика и задачи переезжают вместе со ссылками
+	stats, err := s.ClickStats(ctx, live)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.TotalClicks)
+	require.NoError(t, s.RecordVisits(ctx, []models.Visit{{ShortURL: live, At: now, Browser: "Firefox", OS: "Linux", Device: "desktop"}}))
+	visits, err := s.VisitStats(ctx, live)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), visits.HumanVisits, "imported + new")
+	assert.Equal(t, int64(2), visits.Browsers["Firefox"])
+
+	job, err := s.DeletionJob(ctx, "done")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDone, job.Status)
+	job, err = s.ClaimDeletion(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, "queued", job.ID)
+	require.NoError(t, s.FinishDeletion(ctx, models.DeletionJob{ID: "queued", Status: models.JobDone}))
+
+	record, err := s.Resolve(ctx, live)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/live", record.OriginalURL)
+	record, err = s.Resolve(ctx, deleted)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+
+	// Импортированный URL участвует в дедупликации, а удаленный сокращается заново
+	shortID, err := s.Shorten(ctx, "other", models.ShortenRequest{URL: "https://example.com/live"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, live, shortID)
+	shortID, err = s.Shorten(ctx, "other", models.ShortenRequest{URL: "https://example.com/deleted"})
+	require.NoError(t, err)
+	assert.NotEqual(t, deleted, shortID)
+
+	// Занятый alias откатывает весь батч
+	_, err = s.ShortenBatch(ctx, "user", []models.ShortenRequest{
+		{URL: "https://example.com/new"},
+		{URL: "https://example.com/alias", Alias: live},
+	})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	results, err := s.ShortenBatch(ctx, "user", []models.ShortenRequest{{URL: "https://example.com/new"}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	urls, err := s.ListByUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 2, "live + new, deleted is hidden")
+
+	_, err = s.Delete(ctx, "user", []string{results[0].ShortID})
+	require.NoError(t, err)
+	results, err = s.ShortenBatch(ctx, "user", []models.ShortenRequest{{URL: "https://example.com/new"}})
+	require.NoError(t, err)
+	assert.False(t, results[0].Conflict, "deleted link must not be returned as a conflict")
+	_, err = s.Delete(ctx, "user", []string{results[0].ShortID})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// Повторный старт не импортирует журнал заново и сохраняет изменения
+	_, err = fileStore.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/after-import"})
+	require.NoError(t, err)
+
+	s, err = OpenBolt(filepath.Join(dir, "urls.bolt"), fileStore.Path(), sugar)
+	require.NoError(t, err)
+	defer func(s *BoltStore) {
+		require.NoError(t, s.Close())
+	}(s)
+
+	urls, err = s.ListByUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+}
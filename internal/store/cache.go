@@ -0,0 +1,200 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// CacheStats счетчики кэша редиректов
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"` // Вытеснено по размеру
+	Size      int   `json:"size"`
+}
+
+// cacheEntry запись кэша. found == false - закэширован ErrNotFound
+type cacheEntry struct {
+	shortID string
+	record  models.URLRecord
+	found   bool
+	expires time.Time
+}
+
+// ResolveCache ограниченный по размеру LRU кэш результатов Resolve с временем жизни записей.
+// Кэшируются и найденные записи вместе с DeletedFlag и ExpiresAt, чтобы 410 оставался корректным, и ErrNotFound
+type ResolveCache struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration // 0 - не кэшировать ErrNotFound
+	items       map[string]*list.Element
+	order       *list.List // В начале - недавно использованные
+	generation  uint64     // Растет при каждой инвалидации
+	stats       CacheStats
+	now         func() time.Time
+}
+
+// NewResolveCache создает кэш на size записей
+func NewResolveCache(size int, ttl, negativeTTL time.Duration) *ResolveCache {
+	return &ResolveCache{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		items:       make(map[string]*list.Element, size),
+		order:       list.New(),
+		now:         time.Now,
+	}
+}
+
+// Invalidate убирает записи из кэша
+func (c *ResolveCache) Invalidate(shortIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, shortID := range shortIDs {
+		if elem, ok := c.items[shortID]; ok {
+			c.order.Remove(elem)
+			delete(c.items, shortID)
+		}
+	}
+}
+
+// Purge очищает кэш целиком. Нужен, когда пропущенные уведомления об изменениях восстановить нельзя
+func (c *ResolveCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.items = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
+
+// Stats возвращает текущие счетчики
+func (c *ResolveCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+// get ищет запись в кэше. При промахе возвращает поколение, которое нужно передать в put
+func (c *ResolveCache) get(shortID string) (entry cacheEntry, ok bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.items[shortID]; exists {
+		entry = elem.Value.(cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.stats.Hits++
+			return entry, true, 0
+		}
+		c.order.Remove(elem)
+		delete(c.items, shortID)
+	}
+
+	c.stats.Misses++
+	return cacheEntry{}, false, c.generation
+}
+
+// put сохраняет результат Resolve. Если с момента промаха была инвалидация, результат мог устареть и не сохраняется
+func (c *ResolveCache) put(generation uint64, shortID string, record models.URLRecord, found bool) {
+	ttl := c.ttl
+	if !found {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	entry := cacheEntry{shortID: shortID, record: record, found: found, expires: c.now().Add(ttl)}
+	if elem, exists := c.items[shortID]; exists {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[shortID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(cacheEntry).shortID)
+		c.stats.Evictions++
+	}
+}
+
+// CachedRepository Repository с кэшем Resolve перед основным хранилищем. Изменения ссылок через этот объект
+// сбрасывают их из кэша, остальные методы проксируются как есть. Истекшие ссылки отдельно не сбрасываются:
+// закэшированная запись хранит ExpiresAt, и хендлер отдает 410 по нему
+type CachedRepository struct {
+	Repository
+	cache *ResolveCache
+}
+
+// NewCachedRepository оборачивает repo кэшем
+func NewCachedRepository(repo Repository, cache *ResolveCache) *CachedRepository {
+	return &CachedRepository{Repository: repo, cache: cache}
+}
+
+// Resolve отдает запись из кэша, а при промахе читает ее из хранилища и кэширует
+func (r *CachedRepository) Resolve(ctx context.Context, shortID string) (models.URLRecord, error) {
+	entry, ok, generation := r.cache.get(shortID)
+	if ok {
+		if !entry.found {
+			return models.URLRecord{}, ErrNotFound
+		}
+		return entry.record, nil
+	}
+
+	record, err := r.Repository.Resolve(ctx, shortID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		r.cache.put(generation, shortID, models.URLRecord{}, false)
+	case err == nil:
+		r.cache.put(generation, shortID, record, true)
+	}
+	return record, err
+}
+
+// Shorten сохраняет URL и сбрасывает закэшированный ErrNotFound для нового идентификатора
+func (r *CachedRepository) Shorten(ctx context.Context, userID string, req models.ShortenRequest) (string, error) {
+	shortID, err := r.Repository.Shorten(ctx, userID, req)
+	if shortID != "" {
+		r.cache.Invalidate(shortID)
+	}
+	return shortID, err
+}
+
+// ShortenBatch сохраняет батч и сбрасывает из кэша его идентификаторы
+func (r *CachedRepository) ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	results, err := r.Repository.ShortenBatch(ctx, userID, reqs)
+	shortIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		shortIDs = append(shortIDs, result.ShortID)
+	}
+	r.cache.Invalidate(shortIDs...)
+	return results, err
+}
+
+// Delete помечает ссылки удаленными и сбрасывает их из кэша. Сброс идет и при ошибке: часть ссылок могла успеть удалиться
+func (r *CachedRepository) Delete(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	result, err := r.Repository.Delete(ctx, userID, shortIDs)
+	r.cache.Invalidate(shortIDs...)
+	return result, err
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// countingRepo считает обращения к Resolve основного хранилища
+type countingRepo struct {
+	Repository
+	resolves int
+}
+
+func (r *countingRepo) Resolve(ctx context.Context, shortID string) (models.URLRecord, error) {
+	r.resolves++
+	return r.Repository.Resolve(ctx, shortID)
+}
+
+// TestCachedRepository проверяет попадания, отрицательное кэширование, сброс при удалении и истечение TTL
+func TestCachedRepository(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingRepo{Repository: NewMemoryStore()}
+	cache := NewResolveCache(10, time.Minute, time.Second)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	repo := NewCachedRepository(backend, cache)
+
+	// Отрицательный результат кэшируется, но новая ссылка с этим идентификатором его сбрасывает
+	_, err := repo.Resolve(ctx, "alias")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = repo.Resolve(ctx, "alias")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, backend.resolves)
+
+	shortID, err := repo.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com", Alias: "alias"})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		record, err := repo.Resolve(ctx, shortID)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", record.OriginalURL)
+	}
+	assert.Equal(t, 2, backend.resolves)
+
+	// Удаление сбрасывает запись, и следующий Resolve видит DeletedFlag
+	_, err = repo.Delete(ctx, "user", []string{shortID})
+	require.NoError(t, err)
+	record, err := repo.Resolve(ctx, shortID)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+	assert.Equal(t, 3, backend.resolves)
+
+	// По истечении TTL запись перечитывается
+	now = now.Add(2 * time.Minute)
+	_, err = repo.Resolve(ctx, shortID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, backend.resolves)
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(3), stats.Hits)
+	assert.Equal(t, int64(4), stats.Misses)
+	assert.Equal(t, 1, stats.Size)
+}
+
+// TestResolveCacheEviction проверяет вытеснение давно неиспользованных записей и отказ от устаревшего результата
+func TestResolveCacheEviction(t *testing.T) {
+	cache := NewResolveCache(2, time.Minute, time.Minute)
+
+	_, _, generation := cache.get("a")
+	cache.put(generation, "a", models.URLRecord{ShortURL: "a"}, true)
+	_, _, generation = cache.get("b")
+	cache.put(generation, "b", models.URLRecord{ShortURL: "b"}, true)
+	_, ok, _ := cache.get("a") // a становится недавно использованной
+	assert.True(t, ok)
+
+	_, _, generation = cache.get("c")
+	cache.put(generation, "c", models.URLRecord{ShortURL: "c"}, true)
+	_, ok, _ = cache.get("b")
+	assert.False(t, ok, "b is least recently used")
+	_, ok, _ = cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), cache.Stats().Evictions)
+
+	// Инвалидация между промахом и сохранением: прочитанное значение могло устареть
+	_, _, generation = cache.get("d")
+	cache.Invalidate("d")
+	cache.put(generation, "d", models.URLRecord{ShortURL: "d"}, true)
+	_, ok, _ = cache.get("d")
+	assert.False(t, ok)
+
+	// Полная очистка после потери уведомлений об изменениях
+	cache.Purge()
+	assert.Equal(t, 0, cache.Stats().Size)
+	_, ok, _ = cache.get("a")
+	assert.False(t, ok)
+}
+
+// TestChangeListenerStops проверяет, что слушатель без доступной базы переподключается в фоне и не мешает остановке
+func TestChangeListenerStops(t *testing.T) {
+	listener := StartChangeListener("postgres://127.0.0.1:1/urls?connect_timeout=1", NewResolveCache(1, time.Minute, 0),
+		*zaptest.NewLogger(t).Sugar())
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		listener.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop")
+	}
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Параметры буферизации переходов
+const (
+	ClickBufferSize    = 10000       // Емкость буфера. Переходы сверх нее отбрасываются, чтобы не тормозить редирект
+	ClickBatchSize     = 1000        // Размер пачки, после которого буфер сбрасывается досрочно
+	ClickFlushInterval = time.Second // Период сброса неполной пачки
+)
+
+// clickDateLayout формат даты в дневной гистограмме
+const clickDateLayout = "2006-01-02"
+
+// Click один переход по короткой ссылке
+type Click struct {
+	ShortID string
+	At      time.Time
+}
+
+// aggregateClicks сворачивает переходы в дневные счетчики по каждой ссылке, чтобы хранилище писало одну строку на ссылку и день
+func aggregateClicks(clicks []Click) map[string][]models.DailyClicks {
+	byDay := make(map[string]map[string]models.DailyClicks)
+	for _, click := range clicks {
+		at := click.At.UTC()
+		date := at.Format(clickDateLayout)
+
+		days, ok := byDay[click.ShortID]
+		if !ok {
+			days = make(map[string]models.DailyClicks)
+			byDay[click.ShortID] = days
+		}
+		days[date] = mergeDaily(days[date], models.DailyClicks{Date: date, Clicks: 1, FirstClick: at, LastClick: at})
+	}
+
+	result := make(map[string][]models.DailyClicks, len(byDay))
+	for shortID, days := range byDay {
+		daily := make([]models.DailyClicks, 0, len(days))
+		for _, day := range days {
+			daily = append(daily, day)
+		}
+		sort.Slice(daily, func(i, j int) bool { return daily[i].Date < daily[j].Date })
+		result[shortID] = daily
+	}
+	return result
+}
+
+// mergeDaily складывает счетчики одного дня. Пустой a (Clicks == 0) просто заменяется на b
+func mergeDaily(a, b models.DailyClicks) models.DailyClicks {
+	if a.Clicks == 0 {
+		return b
+	}
+	a.Clicks += b.Clicks
+	if b.FirstClick.Before(a.FirstClick) {
+		a.FirstClick = b.FirstClick
+	}
+	if b.LastClick.After(a.LastClick) {
+		a.LastClick = b.LastClick
+	}
+	return a
+}
+
+// buildStats собирает итоговую статистику из отсортированной по дате гистограммы
+func buildStats(shortID string, daily []models.DailyClicks) models.LinkStats {
+	stats := models.LinkStats{ShortURL: shortID, Daily: daily}
+	if stats.Daily == nil {
+		stats.Daily = []models.DailyClicks{} // В JSON пустой массив, а не null
+	}
+
+	for i, day := range daily {
+		stats.TotalClicks += day.Clicks
+		if i == 0 || day.FirstClick.Before(*stats.FirstClick) {
+			first := day.FirstClick
+			stats.FirstClick = &first
+		}
+		if i == 0 || day.LastClick.After(*stats.LastClick) {
+			last := day.LastClick
+			stats.LastClick = &last
+		}
+	}
+	return stats
+}
+
+// ClickRecorder буферизует переходы и пачками сбрасывает их в хранилище в фоне, чтобы запись не задерживала редирект.
+// Из каждого перехода хранилище получает и прирост счетчика, и событие для журнала посещений
+type ClickRecorder struct {
+	repo    Repository
+	logger  zap.SugaredLogger
+	visits  chan models.Visit
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64 // Переходы, не поместившиеся в буфер
+}
+
+// StartClickRecorder запускает фоновый сброс переходов в repo
+func StartClickRecorder(repo Repository, logger zap.SugaredLogger) *ClickRecorder {
+	r := &ClickRecorder{
+		repo:   repo,
+		logger: logger,
+		visits: make(chan models.Visit, ClickBufferSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+// Record ставит переход в очередь без блокировки. Если буфер заполнен, переход отбрасывается
+func (r *ClickRecorder) Record(visit models.Visit) {
+	select {
+	case r.visits <- visit:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Stop сбрасывает накопленные переходы и останавливает воркер. Вызывается после остановки HTTP сервера
+func (r *ClickRecorder) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *ClickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(ClickFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Visit, 0, ClickBatchSize)
+	for {
+		select {
+		case visit := <-r.visits:
+			batch = append(batch, visit)
+			if len(batch) >= ClickBatchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stop:
+			// Забираем все, что успело попасть в буфер до остановки
+			for {
+				select {
+				case visit := <-r.visits:
+					batch = append(batch, visit)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush пишет пачку в хранилище и возвращает пустой срез для следующей пачки
+func (r *ClickRecorder) flush(batch []models.Visit) []models.Visit {
+	if dropped := r.dropped.Swap(0); dropped > 0 {
+		r.logger.Warnf("Click buffer overflow, %d clicks dropped", dropped)
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	clicks := make([]Click, 0, len(batch))
+	for _, visit := range batch {
+		clicks = append(clicks, Click{ShortID: visit.ShortURL, At: visit.At})
+	}
+
+	ctx := context.Background()
+	if err := r.repo.RecordClicks(ctx, clicks); err != nil {
+		r.logger.Errorf("Error in recording %d clicks: %v", len(clicks), err)
+	}
+	if err := r.repo.RecordVisits(ctx, batch); err != nil {
+		r.logger.Errorf("Error in recording %d visits: %v", len(batch), err)
+	}
+	return batch[:0]
+}
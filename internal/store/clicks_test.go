@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// TestClickStats проверяет агрегацию переходов по дням во всех встроенных хранилищах
+func TestClickStats(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	dir := t.TempDir()
+
+	fileStore, err := OpenFileStore(filepath.Join(dir, "urls.jsonl"), sugar)
+	require.NoError(t, err)
+	sqliteStore, err := OpenSQLite(filepath.Join(dir, "urls.db"))
+	require.NoError(t, err)
+	boltStore, err := OpenBolt(filepath.Join(dir, "urls.bolt"), "", sugar)
+	require.NoError(t, err)
+
+	repos := map[string]Repository{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+		"bolt":   boltStore,
+	}
+
+	day1 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 3, 2, 23, 59, 0, 0, time.UTC)
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			defer func(repo Repository) {
+				require.NoError(t, repo.Close())
+			}(repo)
+
+			shortID, err := repo.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/" + name})
+			require.NoError(t, err)
+
+			stats, err := repo.ClickStats(ctx, shortID)
+			require.NoError(t, err)
+			assert.Zero(t, stats.TotalClicks)
+			assert.Nil(t, stats.FirstClick)
+			assert.Empty(t, stats.Daily)
+
+			// Две пачки: вторая дополняет уже сохраненный день
+			require.NoError(t, repo.RecordClicks(ctx, []Click{
+				{ShortID: shortID, At: day1.Add(time.Hour)},
+				{ShortID: shortID, At: day2},
+				{ShortID: "missing", At: day1},
+			}))
+			require.NoError(t, repo.RecordClicks(ctx, []Click{{ShortID: shortID, At: day1}}))
+
+			stats, err = repo.ClickStats(ctx, shortID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), stats.TotalClicks)
+			require.NotNil(t, stats.FirstClick)
+			assert.True(t, day1.Equal(*stats.FirstClick))
+			assert.True(t, day2.Equal(*stats.LastClick))
+			require.Len(t, stats.Daily, 2)
+			assert.Equal(t, "2024-03-01", stats.Daily[0].Date)
+			assert.Equal(t, int64(2), stats.Daily[0].Clicks)
+			assert.Equal(t, int64(1), stats.Daily[1].Clicks)
+		})
+	}
+}
+
+// TestFileStoreClicksSurviveRestart проверяет, что счетчики переживают перезапуск и компакцию журнала
+func TestFileStoreClicksSurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	shortID, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	recorder := StartClickRecorder(s, sugar)
+	for i := 0; i < 5; i++ {
+		recorder.Record(models.Visit{ShortURL: shortID, At: time.Now(), Browser: "Chrome"})
+	}
+	recorder.Stop() // Остановка сбрасывает накопленные переходы
+
+	require.NoError(t, s.Compact())
+	assert.Equal(t, 3, countLines(t, path), "link + aggregated clicks + aggregated visits")
+
+	s, err = OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	stats, err := s.ClickStats(ctx, shortID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stats.TotalClicks)
+
+	visits, err := s.VisitStats(ctx, shortID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), visits.HumanVisits)
+	assert.Equal(t, int64(5), visits.Browsers["Chrome"])
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// Compactor компактит журнал файлового хранилища по запросу: по сигналу SIGHUP.
+// Тот же сигнал перечитывает список запрещенных доменов, оба действия независимы
+type Compactor struct {
+	store   *FileStore
+	logger  zap.SugaredLogger
+	signals chan os.Signal
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// StartCompactor начинает слушать SIGHUP. Подписка оформляется до возврата, так что сигнал,
+// отправленный сразу после запуска, не потеряется
+func StartCompactor(store *FileStore, logger zap.SugaredLogger) *Compactor {
+	c := &Compactor{
+		store:   store,
+		logger:  logger,
+		signals: make(chan os.Signal, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	signal.Notify(c.signals, syscall.SIGHUP)
+
+	go c.run()
+
+	return c
+}
+
+// Stop перестает слушать сигнал и дожидается окончания начатой компакции. Вызывается до закрытия хранилища
+func (c *Compactor) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+func (c *Compactor) run() {
+	defer close(c.done)
+	defer signal.Stop(c.signals)
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.signals:
+			if err := c.store.Compact(); err != nil {
+				c.logger.Errorf("Failed to compact file storage %s: %v", c.store.Path(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Параметры очереди удаления
+const (
+	DeletionWorkers      = 4               // Количество воркеров
+	DeletionPollInterval = 5 * time.Second // Период проверки очереди без уведомления: задачи других инстансов и прерванные
+
+	// DeletionLease сколько задача может оставаться в статусе running, прежде чем хранилище, общее для нескольких
+	// инстансов, сочтет ее брошенной и отдаст другому воркеру. С большим запасом больше таймаута записи
+	DeletionLease = 5 * time.Minute
+)
+
+// DeletionQueue пул воркеров, разбирающий хранимую в Repository очередь задач на удаление.
+// Задача сначала сохраняется, и только потом ее подхватывает воркер, поэтому принятый запрос не теряется при падении.
+// Повторное выполнение прерванной задачи безопасно: мягкое удаление идемпотентно
+type DeletionQueue struct {
+	repo   Repository
+	logger zap.SugaredLogger
+	wake   chan struct{}
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// StartDeletionQueue возвращает в очередь прерванные задачи и запускает workers воркеров
+func StartDeletionQueue(repo Repository, workers int, logger zap.SugaredLogger) *DeletionQueue {
+	q := &DeletionQueue{
+		repo:   repo,
+		logger: logger,
+		wake:   make(chan struct{}, workers),
+		stop:   make(chan struct{}),
+	}
+
+	requeued, err := repo.RequeueDeletions(context.Background())
+	if err != nil {
+		logger.Errorf("Error in requeueing deletion jobs: %v", err)
+	}
+	if requeued > 0 {
+		logger.Infof("Requeued %d interrupted deletion jobs", requeued)
+	}
+
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.run()
+	}
+
+	return q
+}
+
+// Enqueue сохраняет задачу на удаление ссылок пользователя и будит воркер. Возвращает сохраненную задачу
+func (q *DeletionQueue) Enqueue(ctx context.Context, userID string, shortIDs []string) (models.DeletionJob, error) {
+	now := time.Now().UTC()
+	job := models.DeletionJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		ShortIDs:  shortIDs,
+		Status:    models.JobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := q.repo.EnqueueDeletion(ctx, job); err != nil {
+		return models.DeletionJob{}, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default: // Все воркеры и так разбудят, очередь они разбирают до конца
+	}
+
+	return job, nil
+}
+
+// Stop дожидается окончания текущих задач и останавливает воркеры. Вызывается до закрытия хранилища
+func (q *DeletionQueue) Stop() {
+	close(q.stop)
+	q.wg.Wait()
+}
+
+func (q *DeletionQueue) run() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(DeletionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.drain()
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain выполняет задачи, пока очередь не опустеет или пул не остановят
+func (q *DeletionQueue) drain() {
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		// Начатая задача доводится до конца и при остановке, поэтому контекст не отменяется
+		ctx := context.Background()
+		job, err := q.repo.ClaimDeletion(ctx, time.Now().UTC())
+		if errors.Is(err, ErrNotFound) {
+			return
+		}
+		if err != nil {
+			q.logger.Errorf("Error in claiming deletion job: %v", err)
+			return
+		}
+
+		q.process(ctx, job)
+	}
+}
+
+// process выполняет задачу и сохраняет ее итог
+func (q *DeletionQueue) process(ctx context.Context, job models.DeletionJob) {
+	result, err := q.repo.Delete(ctx, job.UserID, job.ShortIDs)
+	if err != nil {
+		q.logger.Errorf("Error in deletion job %s: %v", job.ID, err)
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = models.JobDone
+		job.Deleted, job.NotFound, job.NotOwned = result.Deleted, result.NotFound, result.NotOwned
+	}
+	job.UpdatedAt = time.Now().UTC()
+
+	if err := q.repo.FinishDeletion(ctx, job); err != nil {
+		q.logger.Errorf("Error in saving deletion job %s: %v", job.ID, err)
+	}
+}
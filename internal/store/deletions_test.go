@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// TestDeletionQueueResumesAfterRestart проверяет, что задачи, не выполненные до остановки, выполняются после перезапуска:
+// и стоявшие в очереди, и прерванные посреди выполнения
+func TestDeletionQueueResumesAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	dir := t.TempDir()
+
+	stores := map[string]func() (Repository, error){
+		"file":   func() (Repository, error) { return OpenFileStore(filepath.Join(dir, "urls.jsonl"), sugar) },
+		"sqlite": func() (Repository, error) { return OpenSQLite(filepath.Join(dir, "urls.db")) },
+		"bolt":   func() (Repository, error) { return OpenBolt(filepath.Join(dir, "urls.bolt"), "", sugar) },
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s, err := open()
+			require.NoError(t, err)
+
+			queued, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/queued"})
+			require.NoError(t, err)
+			running, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/running"})
+			require.NoError(t, err)
+
+			now := time.Now().UTC()
+			first := models.DeletionJob{ID: "first", UserID: "user", ShortIDs: []string{running},
+				Status: models.JobQueued, CreatedAt: now, UpdatedAt: now}
+			second := models.DeletionJob{ID: "second", UserID: "user", ShortIDs: []string{queued},
+				Status: models.JobQueued, CreatedAt: now.Add(time.Millisecond), UpdatedAt: now}
+			require.NoError(t, s.EnqueueDeletion(ctx, first))
+			require.NoError(t, s.EnqueueDeletion(ctx, second))
+
+			// Первую задачу взял воркер, но сервер остановился до ее завершения
+			claimed, err := s.ClaimDeletion(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, "first", claimed.ID)
+			assert.Equal(t, []string{running}, claimed.ShortIDs)
+			require.NoError(t, s.Close())
+
+			s, err = open()
+			require.NoError(t, err)
+			defer func() {
+				_ = s.Close()
+			}()
+
+			deletions := StartDeletionQueue(s, 1, sugar)
+			for _, shortID := range []string{running, queued} {
+				assert.Eventually(t, func() bool {
+					record, err := s.Resolve(ctx, shortID)
+					return err == nil && record.DeletedFlag
+				}, time.Second, 10*time.Millisecond)
+			}
+			deletions.Stop()
+
+			_, err = s.ClaimDeletion(ctx, time.Now())
+			assert.ErrorIs(t, err, ErrNotFound, "queue is drained")
+		})
+	}
+}
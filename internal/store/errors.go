@@ -1,5 +1,7 @@
 package store
 
+import "errors"
+
 // Хранилище ошибок
 const (
 	DefaultError           = "Error"
@@ -9,4 +11,19 @@ const (
 	LargeBodyError         = "Request body too large"
 	ConnectionError        = "Connection error"
 	BadRequestError        = "Bad request"
+	TimeoutError           = "Storage timeout"
+	UnavailableError       = "Storage unavailable"
+	BlockedError           = "URL points to a blocked host"
+	NotFoundError          = "Short URL not found"
+)
+
+// Ошибки, возвращаемые реализациями Repository
+var (
+	ErrConflict = errors.New("url already shortened") // Оригинальный URL уже сокращен, возвращается существующий shortID
+	ErrNotFound = errors.New("short url not found")   // Короткой ссылки нет в хранилище
+
+	ErrIDSpaceExhausted = errors.New("no free short id found") // Генератор не смог подобрать свободный идентификатор
+	ErrAliasTaken       = errors.New("alias already taken")    // Запрошенный пользователем идентификатор занят другой ссылкой
+
+	ErrUnavailable = errors.New("storage unavailable") // Хранилище не ответило и после повторов, оборачивает исходную ошибку
 )
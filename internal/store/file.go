@@ -0,0 +1,526 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Пороги автоматической компакции журнала файлового хранилища по умолчанию
+const (
+	CompactMinSize      = 1 << 20 // Файлы меньше 1MB не компактим, выигрыш не стоит переписывания
+	CompactGarbageRatio = 0.5     // Доля устаревших строк журнала, после которой файл переписывается
+)
+
+// fileOp вид записи в журнале файлового хранилища
+type fileOp string
+
+const (
+	opCreate fileOp = ""       // Новая ссылка. Пустое значение - для совместимости с файлами, записанными до появления op
+	opUpdate fileOp = "update" // Новое состояние существующей ссылки (например, мягкое удаление)
+	opDelete fileOp = "delete" // Tombstone: ссылка удаляется из хранилища полностью
+	opClicks fileOp = "clicks" // Прирост дневных счетчиков переходов по ссылке
+	opVisits fileOp = "visits" // Прирост разбивки посещений ссылки
+	opJob    fileOp = "job"    // Состояние задачи на удаление. Статус running не пишется: прерванная задача выполнится заново
+)
+
+// fileEntry строка журнала: операция и состояние записи после нее
+type fileEntry struct {
+	Op fileOp `json:"op,omitempty"`
+	models.URLRecord
+	Daily  []models.DailyClicks `json:"daily,omitempty"`  // Только для opClicks
+	Visits *models.VisitStats   `json:"visits,omitempty"` // Только для opVisits
+	Job    *models.DeletionJob  `json:"job,omitempty"`    // Только для opJob
+}
+
+// FileStore хранилище ссылок в памяти с дозаписью каждого изменения в JSONL журнал.
+// При старте журнал проигрывается в память: create/update записывают состояние ссылки, delete удаляет ее.
+// Владелец ссылки хранится в поле uuid. Когда устаревших строк становится много, журнал компактится
+type FileStore struct {
+	*MemoryStore
+	mu     sync.Mutex // Сериализует изменения: запись в файл и следующее за ней обновление памяти
+	path   string
+	logger zap.SugaredLogger
+	lines  int   // Количество строк в журнале, включая устаревшие
+	size   int64 // Размер журнала в байтах
+
+	compactMinSize      int64   // Журнал меньше этого размера автоматически не компактится
+	compactGarbageRatio float64 // Доля устаревших строк для автоматической компакции, 0 - только по запросу
+}
+
+// OpenFileStore создает файловое хранилище и восстанавливает ссылки из файла
+func OpenFileStore(path string, logger zap.SugaredLogger) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore:         NewMemoryStore(),
+		path:                path,
+		logger:              logger,
+		compactMinSize:      CompactMinSize,
+		compactGarbageRatio: CompactGarbageRatio,
+	}
+
+	// При большой нагрузке это так себе решение, потому что съедим кучу оперативы, но для PoC - acceptable :)
+	// Вариант с "постоянно дергать файл на Read/Write операции" без использования in-Memory показался совсем варварским.
+	// Для больших объемов есть BoltStore, который умеет импортировать этот же файл при первом старте
+	state, err := replayJournal(path, s.MemoryStore, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.lines, s.size = state.lines, state.size
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Недописанную при падении строку нужно убрать, иначе новые записи окажутся после нее и потеряются при следующем старте
+	if state.broken {
+		if err = s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	s.maybeCompact()
+
+	return s, nil
+}
+
+// Path возвращает путь к файлу хранилища
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Shorten сохраняет URL в файл и в память
+func (s *FileStore) Shorten(ctx context.Context, userID string, req models.ShortenRequest) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Все изменения проходят под s.mu, поэтому проверка занятости и запись не разделены гонкой
+	return generateID(userID, req, func(shortID string) (string, error) {
+		record := newLinkRecord(userID, shortID, req)
+		if existing, err := s.MemoryStore.Resolve(ctx, shortID); err == nil {
+			return reuseOrRetry(existing, record)
+		}
+
+		if err := s.save(opCreate, record); err != nil { // сохраняем в файл
+			return "", err
+		}
+		s.put(record) // сохраняем в память
+		s.maybeCompact()
+
+		return shortID, nil
+	})
+}
+
+// ShortenBatch сохраняет несколько URL в файл и в память
+func (s *FileStore) ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	return shortenEach(ctx, s, userID, reqs)
+}
+
+// Delete помечает ссылки пользователя удаленными в файле и в памяти
+func (s *FileStore) Delete(_ context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, result := s.deleteTargets(userID, shortIDs)
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	for i := range records {
+		records[i].DeletedFlag = true
+	}
+	if err := s.save(opUpdate, records...); err != nil {
+		return DeleteResult{}, err
+	}
+	s.markDeleted(records)
+	s.maybeCompact()
+
+	return result, nil
+}
+
+// EnqueueDeletion дописывает задачу на удаление в журнал и ставит ее в очередь в памяти
+func (s *FileStore) EnqueueDeletion(_ context.Context, job models.DeletionJob) error {
+	return s.saveJob(job)
+}
+
+// FinishDeletion дописывает итог задачи в журнал и в память
+func (s *FileStore) FinishDeletion(_ context.Context, job models.DeletionJob) error {
+	return s.saveJob(job)
+}
+
+// saveJob дописывает состояние задачи в журнал и в память
+func (s *FileStore) saveJob(job models.DeletionJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.saveEntries([]fileEntry{{Op: opJob, Job: &job}}); err != nil {
+		return err
+	}
+	s.putJob(job)
+	s.maybeCompact()
+
+	return nil
+}
+
+// Purge дописывает tombstone для ссылок и удаляет их из памяти
+func (s *FileStore) Purge(ctx context.Context, shortIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]models.URLRecord, 0, len(shortIDs))
+	for _, shortID := range shortIDs {
+		record, err := s.Resolve(ctx, shortID)
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := s.save(opDelete, records...); err != nil {
+		return err
+	}
+	s.remove(shortIDs...)
+	s.maybeCompact()
+
+	return nil
+}
+
+// ReapExpired дописывает tombstone для истекших ссылок и удаляет их из памяти
+func (s *FileStore) ReapExpired(ctx context.Context, now time.Time) (int, error) {
+	expired := s.expired(now)
+	if err := s.Purge(ctx, expired); err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// RecordClicks дописывает прирост счетчиков переходов в журнал и в память
+func (s *FileStore) RecordClicks(ctx context.Context, clicks []Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aggregated := aggregateClicks(clicks)
+	entries := make([]fileEntry, 0, len(aggregated))
+	for shortID, daily := range aggregated {
+		if _, err := s.MemoryStore.Resolve(ctx, shortID); err != nil {
+			continue // Ссылку успели удалить полностью, ее счетчики больше не нужны
+		}
+		entries = append(entries, fileEntry{Op: opClicks, URLRecord: models.URLRecord{ShortURL: shortID}, Daily: daily})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := s.saveEntries(entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.addClicks(entry.ShortURL, entry.Daily)
+	}
+	s.maybeCompact()
+
+	return nil
+}
+
+// RecordVisits дописывает прирост разбивки посещений в журнал и в память. Как и в MemoryStore, сырые события не хранятся
+func (s *FileStore) RecordVisits(ctx context.Context, visits []models.Visit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	aggregated := aggregateVisits(visits)
+	entries := make([]fileEntry, 0, len(aggregated))
+	for shortID, stats := range aggregated {
+		if _, err := s.MemoryStore.Resolve(ctx, shortID); err != nil {
+			continue
+		}
+		entries = append(entries, fileEntry{Op: opVisits, URLRecord: models.URLRecord{ShortURL: shortID}, Visits: &stats})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := s.saveEntries(entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.addVisits(entry.ShortURL, *entry.Visits)
+	}
+	s.maybeCompact()
+
+	return nil
+}
+
+// SetCompaction задает пороги автоматической компакции: размер журнала в байтах и долю устаревших строк в нем.
+// garbageRatio 0 отключает автоматическую компакцию, остается только Compact. Если журнал уже превышает
+// новые пороги, он компактится сразу
+func (s *FileStore) SetCompaction(minSize int64, garbageRatio float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.compactMinSize = minSize
+	s.compactGarbageRatio = garbageRatio
+	s.maybeCompact()
+}
+
+// Compact переписывает журнал, оставляя по одной строке на каждую живую ссылку
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+// save дозапись операций над объектами URLRecord в файл. Вызывается под s.mu
+func (s *FileStore) save(op fileOp, records ...models.URLRecord) error {
+	entries := make([]fileEntry, 0, len(records))
+	for _, record := range records {
+		entries = append(entries, fileEntry{Op: op, URLRecord: record})
+	}
+	return s.saveEntries(entries)
+}
+
+// saveEntries дозапись строк журнала в файл. Вызывается под s.mu
+func (s *FileStore) saveEntries(entries []fileEntry) error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err = file.Close()
+		if err != nil {
+			return
+		}
+	}(file)
+
+	written, err := writeEntries(file, entries)
+	if err != nil {
+		return err
+	}
+
+	s.lines += len(entries)
+	s.size += written
+
+	return nil
+}
+
+// maybeCompact запускает компакцию при достижении порогов размера и доли мусора. Вызывается под s.mu
+// после того, как операция записана в журнал и применена в памяти, поэтому ошибка компакции только логируется
+func (s *FileStore) maybeCompact() {
+	if s.compactGarbageRatio <= 0 || s.size < s.compactMinSize || s.lines == 0 {
+		return
+	}
+
+	// Живая ссылка занимает после компакции строку, и еще по одной на счетчики переходов и разбивку посещений.
+	// Каждая задача на удаление - тоже одна строка
+	clicked, visited := s.clickedCount()
+	garbage := float64(s.lines-s.count()-clicked-visited-s.jobCount()) / float64(s.lines)
+	if garbage < s.compactGarbageRatio {
+		return
+	}
+
+	if err := s.compact(); err != nil {
+		s.logger.Errorf("Failed to compact file storage %s: %v", s.path, err)
+	}
+}
+
+// compact атомарно заменяет журнал снимком текущего состояния: пишем во временный файл рядом,
+// делаем fsync, переименовываем поверх журнала и fsync директории. Вызывается под s.mu
+func (s *FileStore) compact() error {
+	records := s.snapshot()
+	entries := make([]fileEntry, 0, len(records))
+	for _, record := range records {
+		entries = append(entries, fileEntry{Op: opCreate, URLRecord: record})
+		if daily := s.dailyClicks(record.ShortURL); len(daily) > 0 {
+			entries = append(entries, fileEntry{Op: opClicks, URLRecord: models.URLRecord{ShortURL: record.ShortURL}, Daily: daily})
+		}
+		if stats, _ := s.MemoryStore.VisitStats(context.Background(), record.ShortURL); stats.HumanVisits+stats.BotVisits > 0 {
+			entries = append(entries, fileEntry{Op: opVisits, URLRecord: models.URLRecord{ShortURL: record.ShortURL}, Visits: &stats})
+		}
+	}
+	for _, job := range s.jobSnapshot() {
+		if job.Status == models.JobRunning {
+			job.Status = models.JobQueued // Как и при обычной записи, running в журнал не попадает
+		}
+		entries = append(entries, fileEntry{Op: opJob, Job: &job})
+	}
+
+	dir := filepath.Dir(s.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".compact-*")
+	if err != nil {
+		return fmt.Errorf("cannot create temp file: %w", err)
+	}
+	defer func(name string) {
+		_ = os.Remove(name) // После успешного rename файла уже нет, ошибка ожидаема
+	}(tmp.Name())
+
+	written, err := writeEntries(tmp, entries)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("cannot write temp file: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("cannot sync temp file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close temp file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("cannot replace storage file: %w", err)
+	}
+	if err = syncDir(dir); err != nil {
+		return fmt.Errorf("cannot sync storage dir: %w", err)
+	}
+
+	s.logger.Infof("Compacted file storage %s: %d -> %d lines", s.path, s.lines, len(entries))
+	s.lines = len(entries)
+	s.size = written
+
+	return nil
+}
+
+// journalState состояние журнала после проигрывания
+type journalState struct {
+	lines  int   // Количество строк, включая устаревшие
+	size   int64 // Размер прочитанной части в байтах
+	broken bool  // Последняя строка журнала некорректна или недописана
+}
+
+// replayJournal загрузка записей из JSONL журнала в память
+func replayJournal(path string, mem *MemoryStore, logger zap.SugaredLogger) (journalState, error) {
+	var state journalState
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Infof("Файл %s не существует, пропускаем загрузку URL", path)
+			return state, nil
+		}
+		return state, err
+	}
+	defer func(file *os.File) {
+		err = file.Close()
+		if err != nil {
+			return
+		}
+	}(file)
+
+	reader := bufio.NewReader(file)
+	corrupt := 0 // Номер последней некорректной строки, пока неизвестно, последняя ли она в журнале
+
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return state, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		state.size += int64(len(line))
+		// Строка без перевода строки - недописанный при падении хвост, даже если она разбирается:
+		// следующая запись склеилась бы с ней
+		tail := err == io.EOF
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if corrupt > 0 {
+			// За некорректной строкой есть еще записи, значит, это не оборванный хвост: пропускаем только ее
+			logger.Errorf("Пропущена некорректная строка %d журнала %s", corrupt, path)
+			corrupt = 0
+		}
+
+		var entry fileEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			logger.Errorf("Ошибка декодирования JSON в строке %d файлового хранилища: %v", lineNo, err)
+			corrupt = lineNo
+			state.lines++ // Пропущенная строка устаревшая, компакция ее уберет
+			if tail {
+				break
+			}
+			continue
+		}
+		state.lines++
+		state.broken = state.broken || tail
+
+		// Проигрываем операцию в памяти
+		switch entry.Op {
+		case opDelete:
+			mem.remove(entry.ShortURL)
+		case opClicks:
+			mem.addClicks(entry.ShortURL, entry.Daily)
+		case opVisits:
+			if entry.Visits != nil {
+				mem.addVisits(entry.ShortURL, *entry.Visits)
+			}
+		case opJob:
+			if entry.Job != nil {
+				mem.putJob(*entry.Job)
+			}
+		default:
+			mem.put(entry.URLRecord)
+		}
+
+		if tail {
+			break
+		}
+	}
+	// Некорректная последняя строка - запись, оборванная при падении
+	state.broken = state.broken || corrupt > 0
+
+	logger.Infof("Loaded %d URLs from %s (%d log lines)", mem.count(), path, state.lines)
+
+	return state, nil
+}
+
+// writeEntries пишет строки журнала в w и возвращает количество записанных байт
+func writeEntries(w io.Writer, entries []fileEntry) (int64, error) {
+	writer := bufio.NewWriter(w)
+	var written int64
+
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return written, err
+		}
+
+		if _, err := writer.Write(data); err != nil {
+			return written, err
+		}
+
+		if err := writer.WriteByte('\n'); err != nil {
+			return written, err
+		}
+		written += int64(len(data)) + 1
+	}
+
+	return written, writer.Flush()
+}
+
+// syncDir делает fsync директории, чтобы rename пережил падение системы
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func(d *os.File) {
+		err = d.Close()
+		if err != nil {
+			return
+		}
+	}(d)
+
+	return d.Sync()
+}
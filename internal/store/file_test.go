@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+func countLines(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func(file *os.File) {
+		err = file.Close()
+		require.NoError(t, err)
+	}(file)
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+// TestFileStoreCompact проверяет, что tombstone и обновления проигрываются при старте, а компакция оставляет только живые записи
+func TestFileStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+
+	kept, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/kept"})
+	require.NoError(t, err)
+	deleted, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/deleted"})
+	require.NoError(t, err)
+	purged, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/purged"})
+	require.NoError(t, err)
+
+	_, err = s.Delete(ctx, "user", []string{deleted})
+	require.NoError(t, err)
+	require.NoError(t, s.Purge(ctx, []string{purged}))
+	assert.Equal(t, 5, countLines(t, path), "3 create + update + tombstone")
+
+	// Журнал проигрывается так же, как применялся
+	reopened, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+
+	record, err := reopened.Resolve(ctx, deleted)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+	_, err = reopened.Resolve(ctx, purged)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// После компакции в файле по строке на живую ссылку, мягко удаленная остается, чтобы отдавать 410
+	require.NoError(t, reopened.Compact())
+	assert.Equal(t, 2, countLines(t, path))
+
+	compacted, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	record, err = compacted.Resolve(ctx, kept)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/kept", record.OriginalURL)
+	record, err = compacted.Resolve(ctx, deleted)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+
+	leftovers, err := filepath.Glob(path + ".compact-*")
+	require.NoError(t, err)
+	assert.Empty(t, leftovers, "temp files must not survive compaction")
+}
+
+// TestFileStoreBrokenTail проверяет восстановление после недописанной строки в конце журнала
+func TestFileStoreBrokenTail(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	first, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/1"})
+	require.NoError(t, err)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"uuid":"user","short_url":"abc`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// Открытие переписывает журнал без оборванной строки, новые записи не теряются
+	s, err = OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	second, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/2"})
+	require.NoError(t, err)
+
+	s, err = OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	for _, shortID := range []string{first, second} {
+		_, err = s.Resolve(ctx, shortID)
+		assert.NoError(t, err)
+	}
+}
+
+// TestFileStoreCorruptLine проверяет, что некорректная строка в середине журнала пропускается,
+// а записи после нее не теряются ни при старте, ни после него
+func TestFileStoreCorruptLine(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	first, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/1"})
+	require.NoError(t, err)
+
+	// Испорченная строка, за которой идет корректная запись
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"uuid":"user","short_url":"abc` + "\n" +
+		`{"uuid":"user","short_url":"manual","original_url":"https://example.com/manual"}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	s, err = OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	assert.Equal(t, 3, countLines(t, path), "the journal is not rewritten on start")
+	_, err = s.Delete(ctx, "user", []string{first})
+	require.NoError(t, err)
+
+	// Строки после некорректной проигрываются при каждом старте
+	s, err = OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	record, err := s.Resolve(ctx, "manual")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/manual", record.OriginalURL)
+	record, err = s.Resolve(ctx, first)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+	_, err = s.Resolve(ctx, "abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestFileStoreCompactionTriggers проверяет настраиваемые пороги автоматической компакции и компакцию по SIGHUP
+func TestFileStoreCompactionTriggers(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	s.SetCompaction(0, 0) // Только по запросу
+
+	shortID, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	// Каждая пачка переходов - отдельная строка журнала, после компакции от них остается одна
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.RecordClicks(ctx, []Click{{ShortID: shortID, At: time.Now()}}))
+	}
+	require.Equal(t, 4, countLines(t, path))
+
+	// Сигнал компактит журнал, даже когда автоматическая компакция выключена
+	compactor := StartCompactor(s, sugar)
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+	assert.Eventually(t, func() bool { return countLines(t, path) == 2 }, time.Second, 10*time.Millisecond,
+		"create + clicks")
+	compactor.Stop()
+
+	// Новые пороги применяются сразу: 1 устаревшая строка из 3 превышает долю 0.3
+	require.NoError(t, s.RecordClicks(ctx, []Click{{ShortID: shortID, At: time.Now()}}))
+	require.Equal(t, 3, countLines(t, path))
+	s.SetCompaction(0, 0.3)
+	assert.Equal(t, 2, countLines(t, path))
+}
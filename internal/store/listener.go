@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ChangesChannel канал NOTIFY, в который триггер на urls публикует short_url измененных ссылок (миграция 0009)
+const ChangesChannel = "url_changes"
+
+// Задержки переподключения слушателя
+const (
+	listenerMinBackoff = time.Second
+	listenerMaxBackoff = 30 * time.Second
+)
+
+// ChangeListener держит отдельное соединение с PostgreSQL, слушает ChangesChannel и сбрасывает измененные ссылки
+// из кэша редиректов. Так изменения, сделанные другими инстансами, не отдаются из кэша устаревшими
+type ChangeListener struct {
+	dsn    string
+	cache  *ResolveCache
+	logger zap.SugaredLogger
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartChangeListener подключается к базе dsn и запускает прослушивание в фоне. Соединение переустанавливается при обрыве
+func StartChangeListener(dsn string, cache *ResolveCache, logger zap.SugaredLogger) *ChangeListener {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &ChangeListener{
+		dsn:    dsn,
+		cache:  cache,
+		logger: logger,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go l.run(ctx)
+
+	return l
+}
+
+// Stop закрывает соединение и останавливает слушателя
+func (l *ChangeListener) Stop() {
+	l.cancel()
+	<-l.done
+}
+
+func (l *ChangeListener) run(ctx context.Context) {
+	defer close(l.done)
+
+	backoff := listenerMinBackoff
+	for {
+		listened, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			backoff = listenerMinBackoff // Соединение успело поработать, начинаем переподключение с минимальной задержки
+		}
+		l.logger.Warnf("Change listener disconnected, reconnecting in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, listenerMaxBackoff)
+	}
+}
+
+// listen подключается, подписывается на канал и обрабатывает уведомления до ошибки.
+// Возвращает, удалось ли подписаться
+func (l *ChangeListener) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return false, err
+	}
+	defer func(conn *pgx.Conn) {
+		_ = conn.Close(context.Background())
+	}(conn)
+
+	if _, err = conn.Exec(ctx, "LISTEN "+ChangesChannel); err != nil {
+		return false, err
+	}
+
+	// Пока соединения не было, уведомления терялись, и какие ссылки изменились, уже не узнать
+	l.cache.Purge()
+	l.logger.Infof("Listening for URL changes on %q", ChangesChannel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		l.cache.Invalidate(notification.Payload)
+	}
+}
@@ -1,6 +1,442 @@
 package store
 
-// Хранит мапу запросов в памяти. Должно быть заменено на БД, но БД не проходит через CI тесты
-var (
-	URLStore = make(map[string]string) // shortID: originalURL
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JohnnyConstantin/urlshort/internal/config"
+	"github.com/JohnnyConstantin/urlshort/models"
 )
+
+// MemoryStore хранилище ссылок в памяти, без персистентности
+type MemoryStore struct {
+	mu     sync.RWMutex
+	urls   map[string]models.URLRecord              // shortID: запись (в поле UUID хранится владелец, как и в БД)
+	byUser map[string][]string                      // userID: shortID ссылок пользователя в порядке создания
+	clicks map[string]map[string]models.DailyClicks // shortID: дата: переходы за день
+	visits map[string]models.VisitStats             // shortID: разбивка переходов. Сырые события в памяти не храним
+	jobs   map[string]models.DeletionJob            // ID: задача на удаление
+	queue  []string                                 // ID задач в порядке постановки в очередь
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		urls:   make(map[string]models.URLRecord),
+		byUser: make(map[string][]string),
+		clicks: make(map[string]map[string]models.DailyClicks),
+		visits: make(map[string]models.VisitStats),
+		jobs:   make(map[string]models.DeletionJob),
+	}
+}
+
+// Shorten сохраняет URL в память
+func (s *MemoryStore) Shorten(_ context.Context, userID string, req models.ShortenRequest) (string, error) {
+	return generateID(userID, req, func(shortID string) (string, error) {
+		record := newLinkRecord(userID, shortID, req)
+		if existing, inserted := s.insert(record); !inserted {
+			return reuseOrRetry(existing, record)
+		}
+		return shortID, nil
+	})
+}
+
+// ShortenBatch сохраняет несколько URL в память
+func (s *MemoryStore) ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	return shortenEach(ctx, s, userID, reqs)
+}
+
+// Resolve получает из памяти запись по сокращенному URL
+func (s *MemoryStore) Resolve(_ context.Context, shortID string) (models.URLRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.urls[shortID]
+	if !exists {
+		return models.URLRecord{}, ErrNotFound
+	}
+	return record, nil
+}
+
+// ListByUser получает из памяти все неудаленные ссылки пользователя
+func (s *MemoryStore) ListByUser(_ context.Context, userID string) ([]models.URLResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var result []models.URLResponse
+	for _, shortID := range s.byUser[userID] {
+		record := s.urls[shortID]
+		if record.DeletedFlag || record.Expired(now) {
+			continue
+		}
+		result = append(result, models.URLResponse{
+			ShortURL:    config.Options.BaseAddress + "/" + shortID,
+			OriginalURL: record.OriginalURL,
+		})
+	}
+
+	return result, nil
+}
+
+// Delete помечает ссылки пользователя удаленными. Чужие и несуществующие ссылки пропускаются.
+// Поиск и пометка идут под одной блокировкой, иначе параллельный remove вернул бы в память вычищенную запись
+func (s *MemoryStore) Delete(_ context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets, result := s.findDeleteTargets(userID, shortIDs)
+	s.setDeleted(targets)
+	return result, nil
+}
+
+// EnqueueDeletion ставит задачу на удаление в очередь в памяти
+func (s *MemoryStore) EnqueueDeletion(_ context.Context, job models.DeletionJob) error {
+	s.putJob(job)
+	return nil
+}
+
+// ClaimDeletion берет из очереди самую старую задачу
+func (s *MemoryStore) ClaimDeletion(_ context.Context, now time.Time) (models.DeletionJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) > 0 {
+		job := s.jobs[s.queue[0]]
+		s.queue = s.queue[1:]
+		if job.Status != models.JobQueued {
+			continue // Задача попала в очередь повторно и уже взята
+		}
+
+		job.Status = models.JobRunning
+		job.UpdatedAt = now
+		s.jobs[job.ID] = job
+		return job, nil
+	}
+	return models.DeletionJob{}, ErrNotFound
+}
+
+// FinishDeletion сохраняет итог задачи в памяти
+func (s *MemoryStore) FinishDeletion(_ context.Context, job models.DeletionJob) error {
+	s.putJob(job)
+	return nil
+}
+
+// DeletionJob возвращает задачу на удаление из памяти
+func (s *MemoryStore) DeletionJob(_ context.Context, id string) (models.DeletionJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return models.DeletionJob{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// RequeueDeletions возвращает в очередь задачи в статусе running
+func (s *MemoryStore) RequeueDeletions(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var running []models.DeletionJob
+	for _, job := range s.jobs {
+		if job.Status == models.JobRunning {
+			running = append(running, job)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].CreatedAt.Before(running[j].CreatedAt) })
+
+	for _, job := range running {
+		job.Status = models.JobQueued
+		s.jobs[job.ID] = job
+		s.queue = append(s.queue, job.ID)
+	}
+	return len(running), nil
+}
+
+// ReapExpired удаляет истекшие ссылки из памяти
+func (s *MemoryStore) ReapExpired(_ context.Context, now time.Time) (int, error) {
+	expired := s.expired(now)
+	s.remove(expired...)
+	return len(expired), nil
+}
+
+// RecordClicks добавляет переходы к статистике в памяти
+func (s *MemoryStore) RecordClicks(_ context.Context, clicks []Click) error {
+	for shortID, daily := range aggregateClicks(clicks) {
+		s.addClicks(shortID, daily)
+	}
+	return nil
+}
+
+// ClickStats возвращает статистику переходов по ссылке из памяти
+func (s *MemoryStore) ClickStats(_ context.Context, shortID string) (models.LinkStats, error) {
+	return buildStats(shortID, s.dailyClicks(shortID)), nil
+}
+
+// RecordVisits добавляет переходы к разбивке в памяти
+func (s *MemoryStore) RecordVisits(_ context.Context, visits []models.Visit) error {
+	for shortID, stats := range aggregateVisits(visits) {
+		s.addVisits(shortID, stats)
+	}
+	return nil
+}
+
+// VisitStats возвращает разбивку переходов по ссылке из памяти
+func (s *MemoryStore) VisitStats(_ context.Context, shortID string) (models.VisitStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := newVisitStats(shortID)
+	if stored, ok := s.visits[shortID]; ok {
+		mergeVisitStats(&stats, stored) // Копия, чтобы вызывающий не держал ссылки на внутренние map
+	}
+	return stats, nil
+}
+
+// Purge полностью удаляет ссылки из хранилища, в отличие от Delete они перестают отдавать 410
+func (s *MemoryStore) Purge(_ context.Context, shortIDs []string) error {
+	s.remove(shortIDs...)
+	return nil
+}
+
+// Ping память всегда доступна
+func (s *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+// Close освобождать нечего
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// put сохраняет запись, более поздняя запись с тем же shortID заменяет предыдущую
+func (s *MemoryStore) put(record models.URLRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[record.ShortURL]; !exists {
+		s.byUser[record.UUID] = append(s.byUser[record.UUID], record.ShortURL)
+	}
+	s.urls[record.ShortURL] = record
+}
+
+// insert сохраняет запись, только если shortID свободен. Иначе возвращает занимающую его запись
+func (s *MemoryStore) insert(record models.URLRecord) (models.URLRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, exists := s.urls[record.ShortURL]; exists {
+		return existing, false
+	}
+	s.byUser[record.UUID] = append(s.byUser[record.UUID], record.ShortURL)
+	s.urls[record.ShortURL] = record
+	return record, true
+}
+
+// reuseOrRetry решает, что делать с занятым shortID. Детерминированный генератор выдает тот же кандидат
+// при повторном сокращении того же URL тем же пользователем - тогда возвращается существующая ссылка
+func reuseOrRetry(existing, candidate models.URLRecord) (string, error) {
+	if existing.UUID == candidate.UUID && existing.OriginalURL == candidate.OriginalURL && !existing.DeletedFlag {
+		return existing.ShortURL, nil
+	}
+	return "", errIDTaken
+}
+
+// addClicks прибавляет дневные счетчики ссылки. Счетчики удаленной из памяти ссылки не сохраняются
+func (s *MemoryStore) addClicks(shortID string, daily []models.DailyClicks) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[shortID]; !exists {
+		return false
+	}
+
+	days, ok := s.clicks[shortID]
+	if !ok {
+		days = make(map[string]models.DailyClicks)
+		s.clicks[shortID] = days
+	}
+	for _, day := range daily {
+		days[day.Date] = mergeDaily(days[day.Date], day)
+	}
+	return true
+}
+
+// addVisits прибавляет разбивку переходов ссылки. Разбивка удаленной из памяти ссылки не сохраняется
+func (s *MemoryStore) addVisits(shortID string, delta models.VisitStats) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.urls[shortID]; !exists {
+		return false
+	}
+
+	stats, ok := s.visits[shortID]
+	if !ok {
+		stats = newVisitStats(shortID)
+	}
+	mergeVisitStats(&stats, delta)
+	s.visits[shortID] = stats
+	return true
+}
+
+// dailyClicks возвращает гистограмму переходов по ссылке в порядке возрастания даты
+func (s *MemoryStore) dailyClicks(shortID string) []models.DailyClicks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	daily := make([]models.DailyClicks, 0, len(s.clicks[shortID]))
+	for _, day := range s.clicks[shortID] {
+		daily = append(daily, day)
+	}
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Date < daily[j].Date })
+	return daily
+}
+
+// deleteTargets возвращает неудаленные записи из shortIDs, принадлежащие пользователю, и счетчики для DeleteResult
+func (s *MemoryStore) deleteTargets(userID string, shortIDs []string) ([]models.URLRecord, DeleteResult) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.findDeleteTargets(userID, shortIDs)
+}
+
+// findDeleteTargets то же, что deleteTargets. Вызывается под s.mu
+func (s *MemoryStore) findDeleteTargets(userID string, shortIDs []string) ([]models.URLRecord, DeleteResult) {
+	// Поиск в карте не возвращает других ошибок, кроме ErrNotFound
+	targets, result, _ := classifyDeletion(userID, shortIDs, func(shortID string) (models.URLRecord, error) {
+		record, exists := s.urls[shortID]
+		if !exists {
+			return models.URLRecord{}, ErrNotFound
+		}
+		return record, nil
+	})
+	return targets, result
+}
+
+// putJob сохраняет состояние задачи. Задача в статусе queued добавляется в конец очереди
+func (s *MemoryStore) putJob(job models.DeletionJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = job
+	if job.Status == models.JobQueued {
+		s.queue = append(s.queue, job.ID)
+	}
+}
+
+// jobSnapshot возвращает копию всех задач в порядке создания
+func (s *MemoryStore) jobSnapshot() []models.DeletionJob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]models.DeletionJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// markDeleted выставляет DeletedFlag у переданных записей
+func (s *MemoryStore) markDeleted(records []models.URLRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setDeleted(records)
+}
+
+// setDeleted выставляет DeletedFlag у переданных записей. Записи, которых уже нет в памяти, не восстанавливаются.
+// Вызывается под s.mu
+func (s *MemoryStore) setDeleted(records []models.URLRecord) {
+	for _, record := range records {
+		if _, exists := s.urls[record.ShortURL]; !exists {
+			continue
+		}
+		record.DeletedFlag = true
+		s.urls[record.ShortURL] = record
+	}
+}
+
+// remove удаляет записи из памяти вместе с индексом по пользователю
+func (s *MemoryStore) remove(shortIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, shortID := range shortIDs {
+		record, exists := s.urls[shortID]
+		if !exists {
+			continue
+		}
+		delete(s.urls, shortID)
+		delete(s.clicks, shortID)
+		delete(s.visits, shortID)
+
+		userLinks := s.byUser[record.UUID]
+		for i, id := range userLinks {
+			if id == shortID {
+				s.byUser[record.UUID] = append(userLinks[:i], userLinks[i+1:]...)
+				break
+			}
+		}
+		if len(s.byUser[record.UUID]) == 0 {
+			delete(s.byUser, record.UUID)
+		}
+	}
+}
+
+// expired возвращает shortID ссылок, истекших к моменту now
+func (s *MemoryStore) expired(now time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var shortIDs []string
+	for shortID, record := range s.urls {
+		if record.Expired(now) {
+			shortIDs = append(shortIDs, shortID)
+		}
+	}
+	return shortIDs
+}
+
+// snapshot возвращает копию всех записей, сгруппированных по пользователю в порядке создания
+func (s *MemoryStore) snapshot() []models.URLRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]models.URLRecord, 0, len(s.urls))
+	for _, userLinks := range s.byUser {
+		for _, shortID := range userLinks {
+			records = append(records, s.urls[shortID])
+		}
+	}
+	return records
+}
+
+// clickedCount возвращает количество ссылок, по которым были переходы, и количество ссылок с разбивкой посещений
+func (s *MemoryStore) clickedCount() (int, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.clicks), len(s.visits)
+}
+
+// jobCount возвращает количество задач на удаление
+func (s *MemoryStore) jobCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.jobs)
+}
+
+// count возвращает количество записей в хранилище
+func (s *MemoryStore) count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.urls)
+}
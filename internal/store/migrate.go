@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID ключ pg_advisory_lock, чтобы реплики, стартующие одновременно, не применяли миграции параллельно
+const migrationLockID = 0x75726c73686f7274 // "urlshort"
+
+// Имя файла миграции: <версия>_<название>.<up|down>.sql
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration пронумерованная миграция схемы PostgreSQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState миграция и время ее применения (нулевое, если миграция еще не применена)
+type MigrationState struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// Applied применена ли миграция
+func (m MigrationState) Applied() bool {
+	return !m.AppliedAt.IsZero()
+}
+
+// loadMigrations читает встроенные миграции и сортирует их по версии
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		match := migrationFileRe.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", file.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, path.Join("migrations", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// MigrateUp применяет все недостающие миграции и возвращает примененные
+func (d *DB) MigrateUp(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := d.withMigrationLock(ctx, func(conn *sql.Conn, states []MigrationState) error {
+		for _, state := range states {
+			if state.Applied() {
+				continue
+			}
+			if err := applyMigration(ctx, conn, state.Migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, state.Migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// MigrateDown откатывает steps последних примененных миграций и возвращает откаченные
+func (d *DB) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := d.withMigrationLock(ctx, func(conn *sql.Conn, states []MigrationState) error {
+		for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+			if !states[i].Applied() {
+				continue
+			}
+			if err := applyMigration(ctx, conn, states[i].Migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, states[i].Migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// MigrationStatus возвращает все известные миграции с отметкой о применении
+func (d *DB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	var result []MigrationState
+
+	err := d.withMigrationLock(ctx, func(_ *sql.Conn, states []MigrationState) error {
+		result = states
+		return nil
+	})
+
+	return result, err
+}
+
+// withMigrationLock берет advisory lock на выделенном соединении (сессионный lock привязан к соединению),
+// создает таблицу schema_migrations и передает в fn текущее состояние миграций
+func (d *DB) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn, states []MigrationState) error) error {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func(conn *sql.Conn) {
+		err = conn.Close()
+		if err != nil {
+			return
+		}
+	}(conn)
+
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func(conn *sql.Conn) {
+		// Контекст мог быть отменен, а отпустить lock нужно в любом случае
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}(conn)
+
+	_, err = conn.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version    INTEGER PRIMARY KEY,
+        name       TEXT NOT NULL,
+        applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+    )`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	appliedAt := make(map[int]time.Time)
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		appliedAt[version] = at
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		states = append(states, MigrationState{Migration: m, AppliedAt: appliedAt[m.Version]})
+	}
+
+	return fn(conn, states)
+}
+
+// applyMigration выполняет up или down часть миграции и обновляет schema_migrations в одной транзакции
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	query, direction := m.Down, "down"
+	if up {
+		query, direction = m.Up, "up"
+	}
+
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", m.Version, m.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", m.Version, m.Name, err)
+	}
+
+	return tx.Commit()
+}
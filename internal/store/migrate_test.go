@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadMigrations проверяет, что встроенные миграции парные и идут по возрастанию версий без пропусков
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "versions must be sequential")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name: "Missing down file",
+			files: fstest.MapFS{
+				"migrations/0001_init.up.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "Unexpected file name",
+			files: fstest.MapFS{
+				"migrations/init.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "Different names for one version",
+			files: fstest.MapFS{
+				"migrations/0001_init.up.sql":    {Data: []byte("SELECT 1")},
+				"migrations/0001_other.down.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files)
+			assert.Error(t, err)
+		})
+	}
+}
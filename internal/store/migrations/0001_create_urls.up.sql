@@ -0,0 +1,12 @@
+-- Исходная схема. IF NOT EXISTS нужен для баз, созданных до появления миграций старым InitDB
+CREATE TABLE IF NOT EXISTS urls (
+    id           SERIAL PRIMARY KEY,
+    uuid         VARCHAR(36),
+    short_url    VARCHAR(10) UNIQUE NOT NULL,
+    original_url TEXT UNIQUE NOT NULL,
+    is_deleted   BOOLEAN DEFAULT FALSE,
+    created_at   TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_short_url ON urls(short_url);
+CREATE INDEX IF NOT EXISTS idx_original_url ON urls(original_url);
+CREATE INDEX IF NOT EXISTS idx_is_deleted ON urls(is_deleted);
@@ -0,0 +1,2 @@
+-- GET /api/user/urls и удаление фильтруют по владельцу
+CREATE INDEX IF NOT EXISTS idx_uuid ON urls(uuid);
@@ -0,0 +1,2 @@
+-- Откат упадет, если в таблице уже есть идентификаторы длиннее 10 символов
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(10);
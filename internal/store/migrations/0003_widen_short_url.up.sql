@@ -0,0 +1,2 @@
+-- Генераторы идентификаторов удлиняют shortID при заполнении пространства, 10 символов может не хватить
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(64);
@@ -0,0 +1,4 @@
+-- Срок жизни ссылки. NULL - ссылка бессрочная
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+-- Фоновая очистка ищет только живые ссылки с заданным сроком
+CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL AND is_deleted = FALSE;
@@ -0,0 +1,9 @@
+-- Дневные счетчики переходов. Одна строка на ссылку и день, прирост добавляется через ON CONFLICT
+CREATE TABLE IF NOT EXISTS link_clicks (
+    short_url VARCHAR(64) NOT NULL,
+    day       DATE NOT NULL,
+    clicks    BIGINT NOT NULL,
+    first_at  TIMESTAMPTZ NOT NULL,
+    last_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (short_url, day)
+);
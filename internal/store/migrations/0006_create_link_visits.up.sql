@@ -0,0 +1,14 @@
+-- Журнал посещений. Пустая строка bot - переход человека, иначе имя бота
+CREATE TABLE IF NOT EXISTS link_visits (
+    id         BIGSERIAL PRIMARY KEY,
+    short_url  VARCHAR(64) NOT NULL,
+    visited_at TIMESTAMPTZ NOT NULL,
+    referrer   TEXT NOT NULL DEFAULT '',
+    browser    TEXT NOT NULL DEFAULT '',
+    os         TEXT NOT NULL DEFAULT '',
+    device     TEXT NOT NULL DEFAULT '',
+    language   TEXT NOT NULL DEFAULT '',
+    ip         TEXT NOT NULL DEFAULT '',
+    bot        TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_link_visits_short_url ON link_visits(short_url);
@@ -0,0 +1,12 @@
+-- Очередь задач на удаление ссылок. short_urls - JSON массив идентификаторов из запроса
+CREATE TABLE IF NOT EXISTS deletion_jobs (
+    id         VARCHAR(36) PRIMARY KEY,
+    user_id    VARCHAR(36) NOT NULL,
+    short_urls JSONB NOT NULL,
+    status     VARCHAR(16) NOT NULL,
+    error      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+-- Воркеры выбирают самую старую задачу в очереди
+CREATE INDEX IF NOT EXISTS idx_deletion_jobs_queued ON deletion_jobs(created_at) WHERE status = 'queued';
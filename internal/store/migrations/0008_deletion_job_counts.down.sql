@@ -0,0 +1,3 @@
+ALTER TABLE deletion_jobs DROP COLUMN IF EXISTS not_owned;
+ALTER TABLE deletion_jobs DROP COLUMN IF EXISTS not_found;
+ALTER TABLE deletion_jobs DROP COLUMN IF EXISTS deleted;
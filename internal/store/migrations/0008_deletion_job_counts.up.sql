@@ -0,0 +1,4 @@
+-- Итог выполнения задачи на удаление
+ALTER TABLE deletion_jobs ADD COLUMN IF NOT EXISTS deleted   INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE deletion_jobs ADD COLUMN IF NOT EXISTS not_found INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE deletion_jobs ADD COLUMN IF NOT EXISTS not_owned INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS urls_notify_change ON urls;
+DROP FUNCTION IF EXISTS notify_url_change();
@@ -0,0 +1,18 @@
+-- Уведомление об изменении ссылки (удаление, обновление, истечение) для сброса кэша редиректов на всех инстансах.
+-- Вставка тоже уведомляет: у других инстансов мог быть закэширован отрицательный результат для этого short_url.
+-- Payload - short_url. Одинаковые уведомления в пределах транзакции PostgreSQL доставляет один раз
+CREATE OR REPLACE FUNCTION notify_url_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM pg_notify('url_changes', NEW.short_url);
+    ELSE
+        PERFORM pg_notify('url_changes', OLD.short_url);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS urls_notify_change ON urls;
+CREATE TRIGGER urls_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON urls
+    FOR EACH ROW EXECUTE FUNCTION notify_url_change();
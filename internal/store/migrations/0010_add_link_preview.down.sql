@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS interstitial;
+ALTER TABLE urls DROP COLUMN IF EXISTS title;
@@ -0,0 +1,3 @@
+-- Название ссылки для страницы предпросмотра и промежуточная страница вместо редиректа, заданные владельцем
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_urls_original_url_active;
+-- Не применится, если после удаления тот же URL успели сократить повторно
+ALTER TABLE urls ADD CONSTRAINT urls_original_url_key UNIQUE (original_url);
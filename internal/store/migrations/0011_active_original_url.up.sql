@@ -0,0 +1,5 @@
+-- Оригинал уникален только среди живых ссылок: удаленный URL можно сократить заново под новым short_url,
+-- как в остальных хранилищах, а не получить в ответ идентификатор, который отдает 410.
+-- Касается и одиночного Shorten, и батча: ON CONFLICT обоих запросов опирается на этот индекс
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_original_url_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_original_url_active ON urls(original_url) WHERE is_deleted = FALSE;
@@ -0,0 +1,2 @@
+-- Воркеры повторно берут задачи, взятые дольше аренды назад и брошенные упавшим инстансом
+CREATE INDEX IF NOT EXISTS idx_deletion_jobs_running ON deletion_jobs(updated_at) WHERE status = 'running';
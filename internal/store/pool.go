@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Драйверы пула соединений
+const (
+	PoolDriverSQL     = "database/sql" // Пул database/sql поверх драйвера pgx
+	PoolDriverPgxpool = "pgxpool"      // Соединениями управляет pgxpool, database/sql лишь выдает их запросам
+)
+
+// PoolConfig настройки пула соединений с PostgreSQL. Нулевые длительности и размеры оставляют значения драйвера по умолчанию,
+// кроме StatementCache: 0 выключает кэш подготовленных выражений (нужно, например, за pgbouncer в transaction mode)
+type PoolConfig struct {
+	Native            bool          // Использовать pgxpool вместо пула database/sql
+	MaxOpen           int           // Максимум открытых соединений
+	MaxIdle           int           // Максимум простаивающих соединений (для pgxpool - минимум поддерживаемых)
+	MaxLifetime       time.Duration // Время жизни соединения
+	MaxIdleTime       time.Duration // Сколько соединение может простаивать до закрытия
+	HealthCheckPeriod time.Duration // Период проверки простаивающих соединений, только для pgxpool
+	StatementCache    int           // Размер кэша подготовленных выражений на соединение
+}
+
+// PoolStats состояние пула соединений для мониторинга
+type PoolStats struct {
+	Driver            string        `json:"driver"`
+	MaxOpen           int           `json:"max_open"` // 0 - без ограничения
+	Open              int           `json:"open"`
+	InUse             int           `json:"in_use"`
+	Idle              int           `json:"idle"`
+	WaitCount         int64         `json:"wait_count"`    // Сколько раз запрос ждал свободное соединение
+	WaitDuration      time.Duration `json:"wait_duration"` // Суммарное время получения соединений, нс
+	MaxIdleClosed     int64         `json:"max_idle_closed"`
+	MaxLifetimeClosed int64         `json:"max_lifetime_closed"`
+}
+
+// pgPool соединение с PostgreSQL. Запросы всегда идут через db, pool задан, только если соединениями управляет pgxpool
+type pgPool struct {
+	db   *sql.DB
+	pool *pgxpool.Pool
+}
+
+// openPool создает пул соединений с dsn. Соединения открываются лениво, доступность базы здесь не проверяется
+func openPool(dsn string, cfg PoolConfig) (pgPool, error) {
+	if !cfg.Native {
+		connConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return pgPool{}, fmt.Errorf("invalid DSN: %w", err)
+		}
+		cfg.applyStatementCache(connConfig)
+
+		db := stdlib.OpenDB(*connConfig)
+		db.SetMaxOpenConns(cfg.MaxOpen)
+		db.SetMaxIdleConns(cfg.MaxIdle)
+		db.SetConnMaxLifetime(cfg.MaxLifetime)
+		db.SetConnMaxIdleTime(cfg.MaxIdleTime)
+		return pgPool{db: db}, nil
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return pgPool{}, fmt.Errorf("invalid DSN: %w", err)
+	}
+	cfg.applyStatementCache(poolConfig.ConnConfig)
+	if cfg.MaxOpen > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxOpen)
+	}
+	if cfg.MaxIdle > 0 {
+		poolConfig.MinConns = int32(min(cfg.MaxIdle, int(poolConfig.MaxConns)))
+	}
+	if cfg.MaxLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxLifetime
+	}
+	if cfg.MaxIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxIdleTime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return pgPool{}, fmt.Errorf("cannot create pool: %w", err)
+	}
+	return pgPool{db: stdlib.OpenDBFromPool(pool), pool: pool}, nil
+}
+
+// applyStatementCache настраивает кэш подготовленных выражений. Без кэша каждый запрос описывается заново
+// безымянным выражением, что работает и через пулеры соединений
+func (c PoolConfig) applyStatementCache(connConfig *pgx.ConnConfig) {
+	if c.StatementCache > 0 {
+		connConfig.StatementCacheCapacity = c.StatementCache
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+		return
+	}
+	connConfig.StatementCacheCapacity = 0
+	connConfig.DescriptionCacheCapacity = 0
+	connConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+}
+
+// close закрывает пул. Закрытие *sql.DB поверх pgxpool сам pgxpool не закрывает
+func (p pgPool) close() error {
+	err := p.db.Close()
+	if p.pool != nil {
+		p.pool.Close()
+	}
+	return err
+}
+
+// stats возвращает состояние пула
+func (p pgPool) stats() PoolStats {
+	if p.pool != nil {
+		stat := p.pool.Stat()
+		return PoolStats{
+			Driver:            PoolDriverPgxpool,
+			MaxOpen:           int(stat.MaxConns()),
+			Open:              int(stat.TotalConns()),
+			InUse:             int(stat.AcquiredConns()),
+			Idle:              int(stat.IdleConns()),
+			WaitCount:         stat.EmptyAcquireCount(),
+			WaitDuration:      stat.AcquireDuration(),
+			MaxIdleClosed:     stat.MaxIdleDestroyCount(),
+			MaxLifetimeClosed: stat.MaxLifetimeDestroyCount(),
+		}
+	}
+
+	stat := p.db.Stats()
+	return PoolStats{
+		Driver:            PoolDriverSQL,
+		MaxOpen:           stat.MaxOpenConnections,
+		Open:              stat.OpenConnections,
+		InUse:             stat.InUse,
+		Idle:              stat.Idle,
+		WaitCount:         stat.WaitCount,
+		WaitDuration:      stat.WaitDuration,
+		MaxIdleClosed:     stat.MaxIdleClosed + stat.MaxIdleTimeClosed,
+		MaxLifetimeClosed: stat.MaxLifetimeClosed,
+	}
+}
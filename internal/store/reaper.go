@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reaper фоновый воркер, периодически убирающий истекшие ссылки из хранилища
+type Reaper struct {
+	repo     Repository
+	interval time.Duration
+	logger   zap.SugaredLogger
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// StartReaper запускает очистку истекших ссылок раз в interval
+func StartReaper(repo Repository, interval time.Duration, logger zap.SugaredLogger) *Reaper {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Reaper{
+		repo:     repo,
+		interval: interval,
+		logger:   logger,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go r.run(ctx)
+
+	return r
+}
+
+// Stop останавливает воркер и дожидается окончания текущего прохода. Вызывается до закрытия хранилища
+func (r *Reaper) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *Reaper) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reaped, err := r.repo.ReapExpired(ctx, now)
+			if err != nil {
+				if ctx.Err() == nil { // Ошибка из-за остановки не интересна
+					r.logger.Errorf("Error in reaping expired URLs: %v", err)
+				}
+				continue
+			}
+			if reaped > 0 {
+				r.logger.Infof("Reaped %d expired URLs", reaped)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// TestReaper проверяет, что воркер удаляет истекшие ссылки из файлового хранилища, не трогая живые, и корректно останавливается
+func TestReaper(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := OpenFileStore(path, sugar)
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	expired, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/expired", ExpiresAt: &past})
+	require.NoError(t, err)
+	live, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/live", ExpiresAt: &future})
+	require.NoError(t, err)
+
+	// До очистки истекшая ссылка на месте, но скрыта из списка пользователя
+	urls, err := s.ListByUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	reaper := StartReaper(s, 10*time.Millisecond, sugar)
+	assert.Eventually(t, func() bool {
+		_, err := s.Resolve(ctx, expired)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	reaper.Stop()
+
+	// Tombstone пережил перезапуск
+	s, err = OpenFileStore(path, sugar)
+	require.NoError(t, err)
+	_, err = s.Resolve(ctx, expired)
+	assert.ErrorIs(t, err, ErrNotFound)
+	record, err := s.Resolve(ctx, live)
+	require.NoError(t, err)
+	require.NotNil(t, record.ExpiresAt)
+	assert.True(t, future.Equal(*record.ExpiresAt))
+}
+
+// TestSQLiteReapExpired проверяет, что в SQLite истекшие ссылки помечаются удаленными и продолжают отдавать 410
+func TestSQLiteReapExpired(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer func(s *SQLiteStore) {
+		require.NoError(t, s.Close())
+	}(s)
+
+	expiresAt := time.Now().Add(time.Minute)
+	shortID, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	reaped, err := s.ReapExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, reaped)
+
+	reaped, err = s.ReapExpired(ctx, expiresAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, reaped)
+
+	record, err := s.Resolve(ctx, shortID)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+	assert.Equal(t, expiresAt.UnixMilli(), record.ExpiresAt.UnixMilli())
+}
+
+// TestMemoryDeleteDuringReap проверяет, что удаление, совпавшее с очисткой, не возвращает вычищенную ссылку в память
+func TestMemoryDeleteDuringReap(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	past := time.Now().Add(-time.Minute)
+
+	shortID, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/expired", ExpiresAt: &past})
+	require.NoError(t, err)
+	require.NoError(t, s.RecordClicks(ctx, []Click{{ShortID: shortID, At: time.Now()}}))
+
+	// Очистка прошла между поиском удаляемых записей и их пометкой
+	targets, _ := s.deleteTargets("user", []string{shortID})
+	require.Len(t, targets, 1)
+	reaped, err := s.ReapExpired(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, reaped)
+	s.markDeleted(targets)
+
+	_, err = s.Resolve(ctx, shortID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Zero(t, s.count())
+
+	// Delete ищет и помечает под одной блокировкой, вычищенная ссылка для него просто не найдена
+	result, err := s.Delete(ctx, "user", []string{shortID})
+	require.NoError(t, err)
+	assert.Equal(t, DeleteResult{NotFound: 1}, result)
+	assert.Zero(t, s.count())
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Параметры проверки реплик
+const (
+	ReplicaHealthInterval = 5 * time.Second // Период проверки доступности реплик
+	replicaPingTimeout    = 2 * time.Second
+)
+
+// replica реплика PostgreSQL для чтения
+type replica struct {
+	name    string // Хост реплики для логов, без пароля из DSN
+	db      *sql.DB
+	pool    *pgxpool.Pool // Если соединениями управляет pgxpool
+	healthy atomic.Bool
+}
+
+// replicaSet распределяет чтения по доступным репликам. Чтения ключей, записанных недавно (пользователь или ссылка),
+// идут на основную базу, чтобы пользователь видел свои изменения несмотря на отставание реплик
+type replicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	window   time.Duration // Сколько после записи чтения идут на основную базу
+	logger   zap.SugaredLogger
+	next     atomic.Uint64
+
+	mu     sync.Mutex
+	recent map[string]time.Time // Ключ: время последней записи
+	now    func() time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newReplicaSet проверяет реплики и запускает их периодическую проверку
+func newReplicaSet(primary *sql.DB, replicas []*replica, window, interval time.Duration, logger zap.SugaredLogger) *replicaSet {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &replicaSet{
+		primary:  primary,
+		replicas: replicas,
+		window:   window,
+		logger:   logger,
+		recent:   make(map[string]time.Time),
+		now:      time.Now,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	s.check(ctx) // До первой проверки реплики считаются недоступными
+	go s.run(ctx, interval)
+
+	return s
+}
+
+// userKey и linkKey ключи недавних записей
+func userKey(userID string) string  { return "user:" + userID }
+func linkKey(shortID string) string { return "link:" + shortID }
+
+// pick выбирает базу для чтения keys: основную, если какой-то из ключей недавно записан или доступных реплик нет,
+// иначе следующую доступную реплику по кругу
+func (s *replicaSet) pick(keys ...string) *sql.DB {
+	if s.recentlyWritten(keys) {
+		return s.primary
+	}
+
+	// Круг только по доступным репликам, чтобы нагрузка недоступной не уходила целиком на соседнюю
+	healthy := make([]*sql.DB, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r.db)
+		}
+	}
+	if len(healthy) == 0 {
+		return s.primary
+	}
+	return healthy[s.next.Add(1)%uint64(len(healthy))]
+}
+
+// wrote запоминает запись keys
+func (s *replicaSet) wrote(keys ...string) {
+	if s.window <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, key := range keys {
+		s.recent[key] = now
+	}
+}
+
+// failed помечает реплику недоступной до следующей успешной проверки
+func (s *replicaSet) failed(db *sql.DB, err error) {
+	for _, r := range s.replicas {
+		if r.db == db && r.healthy.Swap(false) {
+			s.logger.Warnf("Replica %s failed, reading from primary: %v", r.name, err)
+		}
+	}
+}
+
+// close останавливает проверки и закрывает соединения с репликами
+func (s *replicaSet) close() error {
+	s.cancel()
+	<-s.done
+
+	var firstErr error
+	for _, r := range s.replicas {
+		if err := r.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if r.pool != nil {
+			r.pool.Close()
+		}
+	}
+	return firstErr
+}
+
+func (s *replicaSet) recentlyWritten(keys []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, key := range keys {
+		if at, ok := s.recent[key]; ok && now.Sub(at) < s.window {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *replicaSet) run(ctx context.Context, interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check(ctx)
+		}
+	}
+}
+
+// check пингует реплики и убирает устаревшие ключи недавних записей
+func (s *replicaSet) check(ctx context.Context) {
+	for _, r := range s.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				s.logger.Infof("Replica %s is healthy", r.name)
+			} else if ctx.Err() == nil {
+				s.logger.Warnf("Replica %s is unavailable: %v", r.name, err)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, at := range s.recent {
+		if now.Sub(at) >= s.window {
+			delete(s.recent, key)
+		}
+	}
+}
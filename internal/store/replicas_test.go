@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	return db
+}
+
+// TestReplicaSet проверяет распределение чтений по репликам, обход недоступных и чтение своих записей с основной базы
+func TestReplicaSet(t *testing.T) {
+	primary := openTestDB(t)
+	defer primary.Close()
+
+	first, second, down := openTestDB(t), openTestDB(t), openTestDB(t)
+	require.NoError(t, down.Close()) // Закрытая база не отвечает на ping
+	replicas := []*replica{{name: "first", db: first}, {name: "second", db: second}, {name: "down", db: down}}
+
+	set := newReplicaSet(primary, replicas, time.Second, time.Hour, *zaptest.NewLogger(t).Sugar())
+	defer set.close()
+	now := time.Now()
+	set.now = func() time.Time { return now }
+
+	// Чтения идут по кругу только на доступные реплики
+	picked := map[*sql.DB]int{}
+	for i := 0; i < 6; i++ {
+		picked[set.pick(linkKey("abc"))]++
+	}
+	assert.Equal(t, map[*sql.DB]int{first: 3, second: 3}, picked)
+
+	// Упавшая на запросе реплика исключается до следующей проверки
+	set.failed(first, errors.New("connection reset"))
+	for i := 0; i < 3; i++ {
+		assert.Same(t, second, set.pick(linkKey("abc")))
+	}
+
+	// После записи пользователь и его ссылки читаются с основной базы, пока не истечет окно
+	set.wrote(userKey("user"), linkKey("abc"))
+	assert.Same(t, primary, set.pick(userKey("user")))
+	assert.Same(t, primary, set.pick(linkKey("abc")))
+	assert.Same(t, second, set.pick(userKey("other")))
+
+	now = now.Add(2 * time.Second)
+	assert.Same(t, second, set.pick(userKey("user")))
+
+	// Без доступных реплик все чтения идут на основную базу
+	set.failed(second, errors.New("connection reset"))
+	assert.Same(t, primary, set.pick(linkKey("abc")))
+
+	// Проверка возвращает восстановившиеся реплики
+	set.check(context.Background())
+	assert.NotSame(t, primary, set.pick(linkKey("abc")))
+	assert.Equal(t, []bool{true, true, false},
+		[]bool{replicas[0].healthy.Load(), replicas[1].healthy.Load(), replicas[2].healthy.Load()})
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JohnnyConstantin/urlshort/internal/idgen"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Repository единый интерфейс хранилища ссылок. Реализуется всеми видами хранилища (память, файл, PostgreSQL).
+// Конкретная реализация выбирается один раз при старте сервера, хендлеры работают только с интерфейсом
+type Repository interface {
+	// Shorten сохраняет URL пользователя userID и возвращает короткий идентификатор.
+	// Если URL уже был сокращен, возвращает существующий идентификатор и ErrConflict.
+	// Если задан req.Alias, он используется вместо сгенерированного идентификатора, занятый alias дает ErrAliasTaken
+	Shorten(ctx context.Context, userID string, req models.ShortenRequest) (string, error)
+	// ShortenBatch сокращает несколько URL, результат возвращается в порядке запросов
+	ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error)
+	// Resolve возвращает запись по короткому идентификатору либо ErrNotFound.
+	// Удаленные записи возвращаются с выставленным DeletedFlag
+	Resolve(ctx context.Context, shortID string) (models.URLRecord, error)
+	// ListByUser возвращает все неудаленные и неистекшие ссылки пользователя
+	ListByUser(ctx context.Context, userID string) ([]models.URLResponse, error)
+	// Delete помечает ссылки пользователя удаленными и возвращает счетчики по категориям.
+	// Чужие и несуществующие ссылки пропускаются, повторы в shortIDs учитываются один раз
+	Delete(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error)
+	// EnqueueDeletion сохраняет задачу на удаление в очередь. После возврата задача переживает перезапуск сервера
+	EnqueueDeletion(ctx context.Context, job models.DeletionJob) error
+	// ClaimDeletion берет самую старую задачу из очереди и переводит ее в running. Если очередь пуста, возвращает ErrNotFound
+	ClaimDeletion(ctx context.Context, now time.Time) (models.DeletionJob, error)
+	// FinishDeletion сохраняет итоговый статус задачи
+	FinishDeletion(ctx context.Context, job models.DeletionJob) error
+	// RequeueDeletions возвращает в очередь задачи, прерванные остановкой сервера, и возвращает их количество
+	RequeueDeletions(ctx context.Context) (int, error)
+	// DeletionJob возвращает задачу на удаление по ID либо ErrNotFound
+	DeletionJob(ctx context.Context, id string) (models.DeletionJob, error)
+	// ReapExpired убирает ссылки, истекшие к моменту now, и возвращает их количество.
+	// Хранилища в памяти удаляют их полностью, базы данных помечают удаленными
+	ReapExpired(ctx context.Context, now time.Time) (int, error)
+	// RecordClicks добавляет пачку переходов к статистике. Переходы по отсутствующим ссылкам могут быть отброшены
+	RecordClicks(ctx context.Context, clicks []Click) error
+	// ClickStats возвращает статистику переходов по ссылке. Если переходов не было, статистика пустая
+	ClickStats(ctx context.Context, shortID string) (models.LinkStats, error)
+	// RecordVisits сохраняет пачку событий перехода
+	RecordVisits(ctx context.Context, visits []models.Visit) error
+	// VisitStats возвращает разбивку переходов по ссылке (по источникам, браузерам, ОС, устройствам, языкам и ботам)
+	VisitStats(ctx context.Context, shortID string) (models.VisitStats, error)
+	// Ping проверяет доступность хранилища
+	Ping(ctx context.Context) error
+	// Close освобождает ресурсы хранилища
+	Close() error
+}
+
+// ShortenResult результат сокращения одного URL из батча
+type ShortenResult struct {
+	ShortID  string
+	Conflict bool // URL уже был сокращен ранее, ShortID указывает на существующую запись
+}
+
+// DeleteResult итог удаления ссылок
+type DeleteResult struct {
+	Deleted  int // Ссылки пользователя, включая удаленные ранее
+	NotFound int
+	NotOwned int // Ссылки других пользователей
+}
+
+// classifyDeletion раскладывает shortIDs по категориям DeleteResult и возвращает записи, которые еще нужно пометить удаленными
+func classifyDeletion(userID string, shortIDs []string,
+	lookup func(shortID string) (models.URLRecord, error)) ([]models.URLRecord, DeleteResult, error) {
+	var result DeleteResult
+	var targets []models.URLRecord
+
+	seen := make(map[string]struct{}, len(shortIDs))
+	for _, shortID := range shortIDs {
+		if _, dup := seen[shortID]; dup {
+			continue
+		}
+		seen[shortID] = struct{}{}
+
+		record, err := lookup(shortID)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			result.NotFound++
+		case err != nil:
+			return nil, DeleteResult{}, err
+		case record.UUID != userID:
+			result.NotOwned++
+		default:
+			result.Deleted++
+			if !record.DeletedFlag {
+				targets = append(targets, record)
+			}
+		}
+	}
+	return targets, result, nil
+}
+
+// newLinkRecord запись новой ссылки по запросу на сокращение
+func newLinkRecord(userID, shortID string, req models.ShortenRequest) models.URLRecord {
+	now := time.Now().UTC()
+	return models.URLRecord{
+		UUID:         userID,
+		ShortURL:     shortID,
+		OriginalURL:  req.URL,
+		ExpiresAt:    req.ExpiresAt,
+		CreatedAt:    &now,
+		Title:        req.Title,
+		Interstitial: req.Interstitial,
+	}
+}
+
+// shortenEach сокращает батч поштучно. Используется хранилищами, у которых нет более эффективного способа
+func shortenEach(ctx context.Context, repo Repository, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	results := make([]ShortenResult, 0, len(reqs))
+	for _, req := range reqs {
+		shortID, err := repo.Shorten(ctx, userID, req)
+		if err != nil && !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		results = append(results, ShortenResult{ShortID: shortID, Conflict: err != nil})
+	}
+	return results, nil
+}
+
+// maxIDAttempts сколько кандидатов перебирается до отказа. Генераторы удлиняют идентификатор
+// каждые idgen.AttemptsPerLength коллизий, так что до этого предела доходить не должно
+const maxIDAttempts = 4 * idgen.AttemptsPerLength
+
+// idGenerator генератор коротких идентификаторов, общий для всех хранилищ
+//
+//nolint:gochecknoglobals
+var idGenerator idgen.Generator = idgen.NewRandom(8, idgen.Base62)
+
+// SetIDGenerator заменяет генератор коротких идентификаторов. Вызывается один раз при старте, до открытия хранилища
+func SetIDGenerator(g idgen.Generator) {
+	idGenerator = g
+}
+
+// errIDTaken сигнал от хранилища, что кандидат уже занят и нужно попробовать следующий
+var errIDTaken = errors.New("short id already taken")
+
+// generateID перебирает кандидатов генератора и передает их в insert, пока тот не вернет что-то кроме errIDTaken.
+// insert возвращает итоговый shortID: им может оказаться уже существующая запись (вместе с ErrConflict).
+// Alias из запроса пробуется один раз: перебирать варианты за пользователя нельзя
+func generateID(userID string, req models.ShortenRequest, insert func(shortID string) (string, error)) (string, error) {
+	if req.Alias != "" {
+		shortID, err := insert(req.Alias)
+		if errors.Is(err, errIDTaken) {
+			return "", ErrAliasTaken
+		}
+		return shortID, err
+	}
+
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		shortID, err := insert(idGenerator.Generate(userID, req.URL, attempt))
+		if !errors.Is(err, errIDTaken) {
+			return shortID, err
+		}
+	}
+	return "", ErrIDSpaceExhausted
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/internal/idgen"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// fixedGenerator выдает заранее заданных кандидатов по номеру попытки
+type fixedGenerator []string
+
+func (g fixedGenerator) Generate(_, _ string, attempt int) string {
+	return g[attempt%len(g)]
+}
+
+// TestShortenRetriesOnCollision проверяет, что занятый идентификатор не перезаписывается, а берется следующий кандидат
+func TestShortenRetriesOnCollision(t *testing.T) {
+	ctx := context.Background()
+	defer SetIDGenerator(idGenerator)
+
+	s := NewMemoryStore()
+
+	SetIDGenerator(fixedGenerator{"taken"})
+	first, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/1"})
+	require.NoError(t, err)
+	assert.Equal(t, "taken", first)
+
+	SetIDGenerator(fixedGenerator{"taken", "free"})
+	second, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/2"})
+	require.NoError(t, err)
+	assert.Equal(t, "free", second)
+
+	record, err := s.Resolve(ctx, "taken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", record.OriginalURL)
+
+	SetIDGenerator(fixedGenerator{"taken", "free"})
+	_, err = s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/3"})
+	assert.ErrorIs(t, err, ErrIDSpaceExhausted)
+}
+
+// TestShortenHashReusesLink проверяет, что детерминированный генератор возвращает ту же ссылку при повторном сокращении
+func TestShortenHashReusesLink(t *testing.T) {
+	ctx := context.Background()
+	defer SetIDGenerator(idGenerator)
+	SetIDGenerator(idgen.NewHash(8, idgen.Base62))
+
+	s := NewMemoryStore()
+	first, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	again, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	other, err := s.Shorten(ctx, "other", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+// TestShortenKeepsLinkDetails проверяет, что встроенные хранилища сохраняют название, режим страницы и время создания
+func TestShortenKeepsLinkDetails(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	logger := *zaptest.NewLogger(t).Sugar()
+
+	file, err := OpenFileStore(filepath.Join(dir, "urls.jsonl"), logger)
+	require.NoError(t, err)
+	sqlite, err := OpenSQLite(filepath.Join(dir, "urls.db"))
+	require.NoError(t, err)
+	bolt, err := OpenBolt(filepath.Join(dir, "urls.bolt"), "", logger)
+	require.NoError(t, err)
+
+	repos := map[string]Repository{"memory": NewMemoryStore(), "file": file, "sqlite": sqlite, "bolt": bolt}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			defer repo.Close()
+
+			before := time.Now().Add(-time.Second)
+			shortID, err := repo.Shorten(ctx, "user", models.ShortenRequest{
+				URL:          "https://example.com/" + name,
+				Title:        "Пример",
+				Interstitial: true,
+			})
+			require.NoError(t, err)
+
+			record, err := repo.Resolve(ctx, shortID)
+			require.NoError(t, err)
+			assert.Equal(t, "Пример", record.Title)
+			assert.True(t, record.Interstitial)
+			require.NotNil(t, record.CreatedAt)
+			assert.WithinRange(t, *record.CreatedAt, before, time.Now().Add(time.Second))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Параметры повторов по умолчанию
+const (
+	DefaultRetryBudget = 2 * time.Second // Сколько всего можно потратить на повторы одной операции
+	retryBaseDelay     = 50 * time.Millisecond
+	retryMaxDelay      = time.Second
+)
+
+// RetryPolicy повторы операций при временных ошибках PostgreSQL: обрыв соединения, переключение на реплику,
+// конфликт сериализации. Задержка растет экспоненциально со случайным разбросом, чтобы инстансы не повторяли хором
+type RetryPolicy struct {
+	Budget    time.Duration // Время на повторы, 0 - без повторов
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// NewRetryPolicy политика с бюджетом budget и задержками по умолчанию
+func NewRetryPolicy(budget time.Duration) RetryPolicy {
+	return RetryPolicy{Budget: budget, BaseDelay: retryBaseDelay, MaxDelay: retryMaxDelay}
+}
+
+// do выполняет op, повторяя ее при временных ошибках, пока позволяет бюджет. Если временная ошибка так и не прошла,
+// она оборачивается в ErrUnavailable. Повторять можно только идемпотентные операции
+func (p RetryPolicy) do(ctx context.Context, op func() error) error {
+	deadline := time.Now().Add(p.Budget)
+	delay := p.BaseDelay
+
+	for {
+		err := op()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+
+		// Половина задержки фиксирована, половина случайна
+		wait := delay/2 + rand.N(delay/2+1)
+		if ctx.Err() != nil || time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		case <-time.After(wait):
+		}
+		delay = min(2*delay, p.MaxDelay)
+	}
+}
+
+// retryValue do для операций с результатом
+func retryValue[T any](ctx context.Context, p RetryPolicy, op func() (T, error)) (T, error) {
+	var result T
+	err := p.do(ctx, func() error {
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+// isTransient отличает временные ошибки, после которых операцию имеет смысл повторить
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return true
+		case pgErr.Code == "40001", pgErr.Code == "40P01": // serialization_failure, deadlock_detected
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRetryPolicy проверяет повторы временных ошибок, отказ от повторов остальных и ErrUnavailable по исчерпании бюджета
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{Budget: time.Second, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	// Переключение на реплику: два обрыва, затем успех
+	calls := 0
+	err := policy.do(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return &pgconn.PgError{Code: "57P01"}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Постоянные ошибки не повторяются
+	calls = 0
+	err = policy.do(ctx, func() error {
+		calls++
+		return &pgconn.PgError{Code: "23505"}
+	})
+	assert.NotErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 1, calls)
+
+	// Бюджет исчерпан
+	calls = 0
+	short := RetryPolicy{Budget: 20 * time.Millisecond, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	err = short.do(ctx, func() error {
+		calls++
+		return &pgconn.PgError{Code: "08006"}
+	})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	var pgErr *pgconn.PgError
+	assert.ErrorAs(t, err, &pgErr, "the driver error stays available")
+	assert.Greater(t, calls, 1)
+
+	// Без бюджета временная ошибка сразу отдается как недоступность
+	calls = 0
+	err = RetryPolicy{}.do(ctx, func() error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "08001"}, true},
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "42P01"}, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("boom"), false},
+		{ErrNotFound, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isTransient(tt.err), "%v", tt.err)
+	}
+}
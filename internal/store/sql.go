@@ -4,12 +4,15 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
 
 	"github.com/JohnnyConstantin/urlshort/internal/config"
 	"github.com/JohnnyConstantin/urlshort/models"
@@ -17,75 +20,597 @@ import (
 
 // DB Объект базы данных
 type DB struct {
-	DB *sql.DB
+	DB       *sql.DB
+	pool     pgPool
+	config   PoolConfig  // Настройки пула, с ними же открываются реплики
+	retry    RetryPolicy // Повторы идемпотентных операций при временных ошибках
+	replicas *replicaSet // Реплики для чтения (опционально)
 }
 
-// OpenDB Открыть соединение с БД
-func (d *DB) OpenDB(connStr string) error {
-	sqlDB, err := sql.Open("pgx", connStr)
+// OpenDB Открыть соединение с БД с настройками пула config
+func (d *DB) OpenDB(connStr string, config PoolConfig) error {
+	pool, err := openPool(connStr, config)
 	if err != nil {
 		return fmt.Errorf("не удалось подключиться к БД: %v", err)
 	}
 
-	if err = sqlDB.Ping(); err != nil {
+	if err = pool.db.Ping(); err != nil {
+		_ = pool.close()
 		return fmt.Errorf("не удалось проверить подключение: %v", err)
 	}
 
-	d.DB = sqlDB
+	d.DB = pool.db
+	d.pool = pool
+	d.config = config
+	d.retry = NewRetryPolicy(DefaultRetryBudget)
 	return nil
 }
 
-// InitDB создает базу данных, если ее нет
+// SetRetryBudget задает время на повторы одной операции при временных ошибках, 0 отключает повторы
+func (d *DB) SetRetryBudget(budget time.Duration) {
+	d.retry = NewRetryPolicy(budget)
+}
+
+// PoolStats возвращает состояние пула соединений с основной базой
+func (d *DB) PoolStats() PoolStats {
+	return d.pool.stats()
+}
+
+// OpenReplicas подключает реплики для чтения ссылок. Недоступная при старте реплика не ошибка:
+// она начнет получать чтения после успешной проверки. window - сколько после записи пользователь читает с основной базы
+func (d *DB) OpenReplicas(dsns []string, window time.Duration, logger zap.SugaredLogger) error {
+	replicas := make([]*replica, 0, len(dsns))
+	for _, dsn := range dsns {
+		cfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return fmt.Errorf("invalid replica DSN: %w", err)
+		}
+
+		pool, err := openPool(dsn, d.config)
+		if err != nil {
+			return fmt.Errorf("не удалось подключиться к реплике %s: %v", cfg.Host, err)
+		}
+		replicas = append(replicas, &replica{name: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), db: pool.db, pool: pool.pool})
+	}
+
+	d.replicas = newReplicaSet(d.DB, replicas, window, ReplicaHealthInterval, logger)
+	return nil
+}
+
+// read выполняет чтение keys на реплике, а если она не ответила, повторяет его на основной базе
+func (d *DB) read(ctx context.Context, keys []string, query func(db *sql.DB) error) error {
+	if d.replicas == nil {
+		return query(d.DB)
+	}
+
+	db := d.replicas.pick(keys...)
+	err := query(db)
+	if err == nil || db == d.DB || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+		return err
+	}
+
+	d.replicas.failed(db, err)
+	return query(d.DB)
+}
+
+// wrote запоминает запись keys, чтобы их чтения какое-то время шли на основную базу
+func (d *DB) wrote(keys ...string) {
+	if d.replicas != nil {
+		d.replicas.wrote(keys...)
+	}
+}
+
+// InitDB приводит схему БД к актуальной версии, применяя недостающие миграции
 func (d *DB) InitDB() error {
-	query := `
-    CREATE TABLE IF NOT EXISTS urls (
-        id          SERIAL PRIMARY KEY,
-        uuid        VARCHAR(36),
-        short_url   VARCHAR(10) UNIQUE NOT NULL,
-        original_url TEXT UNIQUE NOT NULL,
-        is_deleted  BOOLEAN DEFAULT FALSE,
-        created_at  TIMESTAMP DEFAULT NOW()
-    );
-    CREATE INDEX IF NOT EXISTS idx_short_url ON urls(short_url);
-    CREATE INDEX IF NOT EXISTS idx_original_url ON urls(original_url);
-    CREATE INDEX IF NOT EXISTS idx_is_deleted ON urls(is_deleted);
-    `
-	_, err := d.DB.ExecContext(context.Background(), query)
-	if err != nil {
-		return fmt.Errorf("failed to create table: %w", err)
+	if _, err := d.MigrateUp(context.Background()); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return nil
 }
 
+// Shorten сохраняет URL в БД. При повторном сокращении того же URL возвращает существующий shortID и ErrConflict
+func (d *DB) Shorten(ctx context.Context, userID string, req models.ShortenRequest) (string, error) {
+	var status int
+
+	shortID, err := generateID(userID, req, func(shortID string) (string, error) {
+		//Создаем объект для записи. Время создания проставляет сама база
+		record := newLinkRecord(userID, shortID, req)
+
+		// Кандидат тот же при каждом повторе, поэтому вставка, успевшая пройти до обрыва, вернет его же
+		var existing string
+		err := d.retry.do(ctx, func() error {
+			var err error
+			existing, status, err = Insert(ctx, d.DB, record, userID)
+			return err
+		})
+		return existing, err
+	})
+	if err != nil {
+		return "", err
+	}
+	d.wrote(userKey(userID), linkKey(shortID))
+	if status == http.StatusConflict {
+		return shortID, ErrConflict
+	}
+
+	return shortID, nil
+}
+
+// batchInsertQuery вставляет батч одним многострочным INSERT из массивов и для каждой позиции возвращает
+// либо новый shortID, либо shortID уже существовавшего оригинала. Основной SELECT видит снимок до INSERT,
+// поэтому existing находит только записи, созданные раньше. Удаленные ссылки не в счет: уникальность original_url
+// действует только среди живых, и такой URL вставится заново. Если не нашлось ни того, ни другого,
+// занят сам кандидат short_url, и позицию нужно повторить с новым кандидатом
+const batchInsertQuery = `
+    WITH input AS (
+        SELECT * FROM unnest($1::text[], $2::text[], $3::timestamptz[], $5::text[], $6::boolean[])
+            WITH ORDINALITY AS t(short_url, original_url, expires_at, title, interstitial, idx)
+    ), inserted AS (
+        INSERT INTO urls (uuid, short_url, original_url, expires_at, title, interstitial)
+        SELECT $4, short_url, original_url, expires_at, title, interstitial FROM input
+        ON CONFLICT DO NOTHING
+        RETURNING short_url, original_url
+    )
+    SELECT input.idx, inserted.short_url, existing.short_url
+    FROM input
+    LEFT JOIN inserted ON inserted.original_url = input.original_url
+    LEFT JOIN urls existing ON existing.original_url = input.original_url AND existing.is_deleted = false
+    ORDER BY input.idx
+`
+
+// ShortenBatch сохраняет батч в одной транзакции. Обычно это один запрос на весь батч,
+// повторные нужны только для позиций, у которых сгенерированный shortID оказался занят
+func (d *DB) ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	results := make([]ShortenResult, len(reqs))
+
+	// Повторы оригинала внутри батча получают результат первого вхождения с флагом конфликта
+	first := make(map[string]int, len(reqs))
+	duplicates := make(map[int]int)
+	pending := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		if j, ok := first[req.URL]; ok {
+			duplicates[i] = j
+			continue
+		}
+		first[req.URL] = i
+		pending = append(pending, i)
+	}
+
+	// Транзакция повторяется целиком. Если до обрыва успел пройти Commit, повтор найдет вставленные
+	// оригиналы и вернет их shortID как конфликт
+	err := d.retry.do(ctx, func() error {
+		return d.shortenBatchTx(ctx, userID, reqs, pending, results)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := range duplicates {
+		results[i] = ShortenResult{ShortID: results[j].ShortID, Conflict: true}
+	}
+
+	keys := []string{userKey(userID)}
+	for _, result := range results {
+		keys = append(keys, linkKey(result.ShortID))
+	}
+	d.wrote(keys...)
+
+	return results, nil
+}
+
+// shortenBatchTx вставляет позиции pending в одной транзакции и заполняет results
+func (d *DB) shortenBatchTx(ctx context.Context, userID string, reqs []models.ShortenRequest,
+	pending []int, results []ShortenResult) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt >= maxIDAttempts {
+			return ErrIDSpaceExhausted
+		}
+
+		if pending, err = insertBatch(ctx, tx, userID, reqs, pending, attempt, results); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertBatch выполняет batchInsertQuery для позиций pending, заполняет results и возвращает позиции,
+// которые нужно повторить из-за занятого shortID
+func insertBatch(ctx context.Context, tx *sql.Tx, userID string, reqs []models.ShortenRequest,
+	pending []int, attempt int, results []ShortenResult) ([]int, error) {
+	shortIDs := make([]string, 0, len(pending))
+	originals := make([]string, 0, len(pending))
+	expires := make([]*time.Time, 0, len(pending))
+	titles := make([]string, 0, len(pending))
+	interstitials := make([]bool, 0, len(pending))
+	for _, i := range pending {
+		candidate := reqs[i].Alias
+		if candidate == "" {
+			candidate = idGenerator.Generate(userID, reqs[i].URL, attempt)
+		}
+		shortIDs = append(shortIDs, candidate)
+		originals = append(originals, reqs[i].URL)
+		expires = append(expires, reqs[i].ExpiresAt)
+		titles = append(titles, reqs[i].Title)
+		interstitials = append(interstitials, reqs[i].Interstitial)
+	}
+
+	rows, err := tx.QueryContext(ctx, batchInsertQuery, shortIDs, originals, expires, userID, titles, interstitials)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var retry []int
+	for rows.Next() {
+		var idx int
+		var inserted, existing sql.NullString
+		if err = rows.Scan(&idx, &inserted, &existing); err != nil {
+			return nil, err
+		}
+
+		i := pending[idx-1] // WITH ORDINALITY нумерует с единицы
+		switch {
+		case inserted.Valid:
+			results[i] = ShortenResult{ShortID: inserted.String}
+		case existing.Valid:
+			results[i] = ShortenResult{ShortID: existing.String, Conflict: true}
+		case reqs[i].Alias != "":
+			return nil, ErrAliasTaken // Транзакция откатится целиком, как и в остальных хранилищах
+		default:
+			retry = append(retry, i)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return retry, nil
+}
+
+// Resolve получает из БД запись по сокращенному URL
+func (d *DB) Resolve(ctx context.Context, shortID string) (models.URLRecord, error) {
+	var record models.URLRecord
+	err := d.retry.do(ctx, func() error {
+		return d.read(ctx, []string{linkKey(shortID)}, func(db *sql.DB) error {
+			var err error
+			record, err = Read(ctx, db, shortID)
+			return err
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.URLRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return models.URLRecord{}, err
+	}
+
+	return record, nil
+}
+
+// ListByUser получает из БД все ссылки пользователя
+func (d *DB) ListByUser(ctx context.Context, userID string) ([]models.URLResponse, error) {
+	var urls []models.URLResponse
+	err := d.retry.do(ctx, func() error {
+		return d.read(ctx, []string{userKey(userID)}, func(db *sql.DB) error {
+			var err error
+			urls, err = ReadWithUUID(ctx, db, userID)
+			return err
+		})
+	})
+	return urls, err
+}
+
+// deleteQuery помечает ссылки пользователя удаленными и одновременно считает категории DeleteResult.
+// Изменяющий CTE выполняется, даже если на него не ссылаются, а основной SELECT видит снимок до UPDATE,
+// поэтому ранее удаленные ссылки владельца тоже попадают в deleted
+const deleteQuery = `
+    WITH req AS (
+        SELECT DISTINCT unnest($2::text[]) AS short_url
+    ), upd AS (
+        UPDATE urls SET is_deleted = true
+        FROM req
+        WHERE urls.short_url = req.short_url AND urls.uuid = $1 AND urls.is_deleted = false
+    )
+    SELECT
+        count(*) FILTER (WHERE u.uuid = $1),
+        count(*) FILTER (WHERE u.short_url IS NULL),
+        count(*) FILTER (WHERE u.short_url IS NOT NULL AND u.uuid IS DISTINCT FROM $1)
+    FROM req
+    LEFT JOIN urls u ON u.short_url = req.short_url
+`
+
+// Delete помечает ссылки пользователя удаленными одним запросом
+func (d *DB) Delete(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	var result DeleteResult
+	err := d.retry.do(ctx, func() error {
+		return d.DB.QueryRowContext(ctx, deleteQuery, userID, shortIDs).
+			Scan(&result.Deleted, &result.NotFound, &result.NotOwned)
+	})
+	if err != nil {
+		return DeleteResult{}, fmt.Errorf("database error: %w", err)
+	}
+
+	keys := []string{userKey(userID)}
+	for _, shortID := range shortIDs {
+		keys = append(keys, linkKey(shortID))
+	}
+	d.wrote(keys...)
+
+	return result, nil
+}
+
+// EnqueueDeletion сохраняет задачу на удаление в таблицу очереди
+func (d *DB) EnqueueDeletion(ctx context.Context, job models.DeletionJob) error {
+	shortIDs, err := json.Marshal(job.ShortIDs)
+	if err != nil {
+		return err
+	}
+
+	return d.retry.do(ctx, func() error {
+		_, err := d.DB.ExecContext(ctx, `
+            INSERT INTO deletion_jobs (id, user_id, short_urls, status, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            ON CONFLICT (id) DO NOTHING`,
+			job.ID, job.UserID, string(shortIDs), job.Status, job.CreatedAt, job.UpdatedAt)
+		return err
+	})
+}
+
+// ClaimDeletion берет самую старую задачу из очереди. SKIP LOCKED позволяет нескольким инстансам
+// разбирать одну очередь, не дожидаясь друг друга. Задача, взятая дольше DeletionLease назад, считается брошенной
+// упавшим инстансом и берется повторно
+func (d *DB) ClaimDeletion(ctx context.Context, now time.Time) (models.DeletionJob, error) {
+	return scanPostgresJob(d.DB.QueryRowContext(ctx, `
+        UPDATE deletion_jobs SET status = $1, updated_at = $2
+        WHERE id = (
+            SELECT id FROM deletion_jobs
+            WHERE status = $3 OR (status = $1 AND updated_at < $4)
+            ORDER BY created_at LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING `+deletionJobColumns,
+		models.JobRunning, now, models.JobQueued, now.Add(-DeletionLease),
+	))
+}
+
+// FinishDeletion сохраняет итоговый статус задачи
+func (d *DB) FinishDeletion(ctx context.Context, job models.DeletionJob) error {
+	return d.retry.do(ctx, func() error {
+		_, err := d.DB.ExecContext(ctx, `
+            UPDATE deletion_jobs SET status = $1, error = $2, updated_at = $3, deleted = $4, not_found = $5, not_owned = $6
+            WHERE id = $7`,
+			job.Status, job.Error, job.UpdatedAt, job.Deleted, job.NotFound, job.NotOwned, job.ID)
+		return err
+	})
+}
+
+// DeletionJob возвращает задачу на удаление по ID
+func (d *DB) DeletionJob(ctx context.Context, id string) (models.DeletionJob, error) {
+	return retryValue(ctx, d.retry, func() (models.DeletionJob, error) {
+		return scanPostgresJob(d.DB.QueryRowContext(ctx, `SELECT `+deletionJobColumns+` FROM deletion_jobs WHERE id = $1`, id))
+	})
+}
+
+// deletionJobColumns колонки задачи на удаление в порядке сканирования, общие для PostgreSQL и SQLite
+const deletionJobColumns = `id, user_id, short_urls, status, error, created_at, updated_at, deleted, not_found, not_owned`
+
+// scanPostgresJob читает задачу из строки с колонками deletionJobColumns
+func scanPostgresJob(row *sql.Row) (models.DeletionJob, error) {
+	var job models.DeletionJob
+	var shortIDs []byte
+
+	err := row.Scan(&job.ID, &job.UserID, &shortIDs, &job.Status, &job.Error, &job.CreatedAt, &job.UpdatedAt,
+		&job.Deleted, &job.NotFound, &job.NotOwned)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.DeletionJob{}, ErrNotFound
+	case err != nil:
+		return models.DeletionJob{}, err
+	}
+
+	if err = json.Unmarshal(shortIDs, &job.ShortIDs); err != nil {
+		return models.DeletionJob{}, fmt.Errorf("broken deletion job %s: %w", job.ID, err)
+	}
+	return job, nil
+}
+
+// RequeueDeletions возвращает в очередь задачи, взятые дольше DeletionLease назад. Таблица общая для всех инстансов,
+// поэтому более свежие задачи могут выполняться живыми соседями и не трогаются. Собственные задачи,
+// прерванные быстрым перезапуском, подберет ClaimDeletion, когда истечет их аренда
+func (d *DB) RequeueDeletions(ctx context.Context) (int, error) {
+	res, err := retryValue(ctx, d.retry, func() (sql.Result, error) {
+		return d.DB.ExecContext(ctx,
+			`UPDATE deletion_jobs SET status = $1 WHERE status = $2 AND updated_at < $3`,
+			models.JobQueued, models.JobRunning, time.Now().UTC().Add(-DeletionLease))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	requeued, err := res.RowsAffected()
+	return int(requeued), err
+}
+
+// ReapExpired помечает истекшие ссылки удаленными, чтобы они продолжали отдавать 410
+func (d *DB) ReapExpired(ctx context.Context, now time.Time) (int, error) {
+	res, err := retryValue(ctx, d.retry, func() (sql.Result, error) {
+		return d.DB.ExecContext(ctx,
+			`UPDATE urls SET is_deleted = true WHERE expires_at <= $1 AND is_deleted = false`, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	reaped, err := res.RowsAffected()
+	return int(reaped), err
+}
+
+// RecordClicks добавляет прирост дневных счетчиков переходов в одной транзакции
+func (d *DB) RecordClicks(ctx context.Context, clicks []Click) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	for shortID, daily := range aggregateClicks(clicks) {
+		for _, day := range daily {
+			_, err = tx.ExecContext(ctx, `
+            INSERT INTO link_clicks (short_url, day, clicks, first_at, last_at)
+            VALUES ($1, $2::date, $3, $4, $5)
+            ON CONFLICT (short_url, day) DO UPDATE SET
+                clicks   = link_clicks.clicks + EXCLUDED.clicks,
+                first_at = LEAST(link_clicks.first_at, EXCLUDED.first_at),
+                last_at  = GREATEST(link_clicks.last_at, EXCLUDED.last_at)
+            `, shortID, day.Date, day.Clicks, day.FirstClick, day.LastClick)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ClickStats возвращает статистику переходов по ссылке из БД
+func (d *DB) ClickStats(ctx context.Context, shortID string) (models.LinkStats, error) {
+	rows, err := retryValue(ctx, d.retry, func() (*sql.Rows, error) {
+		return d.DB.QueryContext(ctx, `
+            SELECT to_char(day, 'YYYY-MM-DD'), clicks, first_at, last_at FROM link_clicks
+            WHERE short_url = $1 ORDER BY day`,
+			shortID,
+		)
+	})
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf("database query error: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var daily []models.DailyClicks
+	for rows.Next() {
+		var day models.DailyClicks
+		if err = rows.Scan(&day.Date, &day.Clicks, &day.FirstClick, &day.LastClick); err != nil {
+			return models.LinkStats{}, err
+		}
+		daily = append(daily, day)
+	}
+	if err = rows.Err(); err != nil {
+		return models.LinkStats{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return buildStats(shortID, daily), nil
+}
+
+// RecordVisits сохраняет события перехода в одной транзакции
+func (d *DB) RecordVisits(ctx context.Context, visits []models.Visit) error {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	for _, v := range visits {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO link_visits (short_url, visited_at, referrer, browser, os, device, language, ip, bot)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			v.ShortURL, v.At, v.Referrer, v.Browser, v.OS, v.Device, v.Language, v.IP, v.Bot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// VisitStats считает разбивку посещений ссылки одним запросом
+func (d *DB) VisitStats(ctx context.Context, shortID string) (models.VisitStats, error) {
+	rows, err := retryValue(ctx, d.retry, func() (*sql.Rows, error) {
+		return d.DB.QueryContext(ctx, visitBreakdownQuery("$1"), shortID)
+	})
+	if err != nil {
+		return models.VisitStats{}, fmt.Errorf("database query error: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	stats := newVisitStats(shortID)
+	for rows.Next() {
+		var dimension, value string
+		var n int64
+		if err = rows.Scan(&dimension, &value, &n); err != nil {
+			return models.VisitStats{}, err
+		}
+		addBreakdown(&stats, dimension, value, n)
+	}
+	if err = rows.Err(); err != nil {
+		return models.VisitStats{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Ping проверяет подключение к БД
+func (d *DB) Ping(ctx context.Context) error {
+	return d.DB.PingContext(ctx)
+}
+
+// Close закрывает соединение с БД. Close самостоятельно дожидается окончания всех начатых операций с БД
+func (d *DB) Close() error {
+	if d.replicas != nil {
+		if err := d.replicas.close(); err != nil {
+			return err
+		}
+	}
+	return d.pool.close()
+}
+
 // Insert вставляет originalURL и shortKey в БД
-func Insert(db *sql.DB, record models.URLRecord, uuid string) (string, int, error) {
+func Insert(ctx context.Context, db *sql.DB, record models.URLRecord, uuid string) (string, int, error) {
 	var existingShortURL string
 	var status int
 	shortKey := record.ShortURL
 	originalURL := record.OriginalURL
 
 	// Вставляем запись в БД (если OriginalURL уже есть, возвращаем существующий shortURL)
-	err := db.QueryRow(`
+	err := db.QueryRowContext(ctx, `
         WITH insert_attempt AS (
-            INSERT INTO urls (uuid, short_url, original_url)
-            VALUES ($1, $2, $3)
-            ON CONFLICT (original_url) DO NOTHING
+            INSERT INTO urls (uuid, short_url, original_url, expires_at, title, interstitial)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            ON CONFLICT (original_url) WHERE is_deleted = false DO NOTHING
             RETURNING short_url
         )
         SELECT * FROM insert_attempt
         UNION
         SELECT short_url FROM urls WHERE original_url = $3 AND is_deleted = false
         LIMIT 1
-    `, uuid, shortKey, originalURL).Scan(&existingShortURL)
+    `, uuid, shortKey, originalURL, record.ExpiresAt, record.Title, record.Interstitial).Scan(&existingShortURL)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // Товарищи в пачке уже сталкивались, у UniqueViolation именно такой код
-			// Просто возвращаем ошибку. Можно было бы перегенерировать shortURL, либо сделать роллбек через транзакции, но в ТЗ этого не указано, поэтому просто Error
-			return existingShortURL, http.StatusConflict, nil
+			// Конфликт по original_url гасит ON CONFLICT, значит занят сам short_url. Вызывающий сгенерирует новый
+			return "", 0, errIDTaken
 		}
-		return existingShortURL, http.StatusConflict, fmt.Errorf("database error: %v", err)
+		return "", 0, fmt.Errorf("database error: %w", err)
 	}
 
 	if existingShortURL == record.ShortURL {
@@ -97,33 +622,37 @@ func Insert(db *sql.DB, record models.URLRecord, uuid string) (string, int, erro
 	return existingShortURL, status, nil
 }
 
-// Read Вычитывает original_url по shortID
-func Read(db *sql.DB, shortID string) (string, bool, bool, error) {
-	var originalURL string
-	var isDeleted bool
+// Read Вычитывает запись по shortID. Если записи нет, возвращает sql.ErrNoRows
+func Read(ctx context.Context, db *sql.DB, shortID string) (models.URLRecord, error) {
+	record := models.URLRecord{ShortURL: shortID}
+	var owner sql.NullString
+	var expiresAt, createdAt sql.NullTime
 
-	err := db.QueryRow(
-		`SELECT original_url, is_deleted FROM urls WHERE short_url = $1`,
+	err := db.QueryRowContext(ctx,
+		`SELECT uuid, original_url, is_deleted, expires_at, created_at, title, interstitial FROM urls WHERE short_url = $1`,
 		shortID,
-	).Scan(&originalURL, &isDeleted)
+	).Scan(&owner, &record.OriginalURL, &record.DeletedFlag, &expiresAt, &createdAt, &record.Title, &record.Interstitial)
+	if err != nil {
+		return models.URLRecord{}, err
+	}
 
-	switch {
-	case err == nil:
-		return originalURL, true, isDeleted, nil
-	case errors.Is(err, sql.ErrNoRows):
-		return "", false, false, err
-	default:
-		return "", false, false, err
+	record.UUID = owner.String
+	if expiresAt.Valid {
+		record.ExpiresAt = &expiresAt.Time
 	}
+	if createdAt.Valid {
+		record.CreatedAt = &createdAt.Time
+	}
+	return record, nil
 }
 
 // ReadWithUUID Вычитывает original_url по shortID и userID
-func ReadWithUUID(db *sql.DB, userID string) ([]models.URLResponse, error) {
+func ReadWithUUID(ctx context.Context, db *sql.DB, userID string) ([]models.URLResponse, error) {
 	var result []models.URLResponse
 
-	rows, err := db.Query(
+	rows, err := db.QueryContext(ctx,
 		`SELECT short_url, original_url FROM urls 
-         WHERE uuid = $1 AND is_deleted = false`,
+         WHERE uuid = $1 AND is_deleted = false AND (expires_at IS NULL OR expires_at > NOW())`,
 		userID,
 	)
 	if err != nil {
@@ -152,32 +681,3 @@ func ReadWithUUID(db *sql.DB, userID string) ([]models.URLResponse, error) {
 
 	return result, nil
 }
-
-// DeleteURLs Удаление ссылок
-func DeleteURLs(db *sql.DB, userID string, batch []string) error {
-
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func(tx *sql.Tx) {
-		err = tx.Rollback()
-		if err != nil {
-			return
-		}
-	}(tx)
-
-	for _, shortURL := range batch {
-		_, err = tx.Exec("UPDATE urls SET is_deleted = true WHERE short_url = $1 AND uuid = $2", shortURL, userID)
-		if err != nil {
-			return err
-		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
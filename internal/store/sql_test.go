@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// openPostgres подключается к PostgreSQL из TEST_DATABASE_DSN, без него тест пропускается
+func openPostgres(t *testing.T) *DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	var db DB
+	require.NoError(t, db.OpenDB(dsn, PoolConfig{}))
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.InitDB())
+	return &db
+}
+
+// TestDBShortenBatchAfterDelete проверяет, что батч не возвращает удаленную ссылку как конфликт
+func TestDBShortenBatchAfterDelete(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+	userID := uuid.NewString()
+	deleted := "https://example.com/deleted/" + userID
+	live := "https://example.com/live/" + userID
+
+	oldID, err := db.Shorten(ctx, userID, models.ShortenRequest{URL: deleted})
+	require.NoError(t, err)
+	liveID, err := db.Shorten(ctx, userID, models.ShortenRequest{URL: live})
+	require.NoError(t, err)
+	_, err = db.Delete(ctx, userID, []string{oldID})
+	require.NoError(t, err)
+
+	results, err := db.ShortenBatch(ctx, userID, []models.ShortenRequest{{URL: deleted}, {URL: live}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.False(t, results[0].Conflict)
+	assert.NotEqual(t, oldID, results[0].ShortID)
+	record, err := db.Resolve(ctx, results[0].ShortID)
+	require.NoError(t, err)
+	assert.False(t, record.DeletedFlag)
+	assert.Equal(t, deleted, record.OriginalURL)
+
+	assert.Equal(t, ShortenResult{ShortID: liveID, Conflict: true}, results[1])
+
+	// Одиночное сокращение ведет себя так же
+	_, err = db.Delete(ctx, userID, []string{results[0].ShortID})
+	require.NoError(t, err)
+	shortID, err := db.Shorten(ctx, userID, models.ShortenRequest{URL: deleted})
+	require.NoError(t, err)
+	assert.NotEqual(t, results[0].ShortID, shortID)
+}
+
+// TestDBRequeueDeletionsLease проверяет, что при старте в очередь возвращаются только задачи с истекшей арендой,
+// а не те, что еще выполняют другие инстансы
+func TestDBRequeueDeletionsLease(t *testing.T) {
+	db := openPostgres(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	newJob := func() models.DeletionJob {
+		job := models.DeletionJob{ID: uuid.NewString(), UserID: uuid.NewString(), ShortIDs: []string{"abc"},
+			Status: models.JobQueued, CreatedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Hour)}
+		require.NoError(t, db.EnqueueDeletion(ctx, job))
+		return job
+	}
+	status := func(id string) string {
+		job, err := db.DeletionJob(ctx, id)
+		require.NoError(t, err)
+		return job.Status
+	}
+
+	// Задачи создаются часом раньше, поэтому берутся раньше всех остальных в таблице
+	live, abandoned := newJob(), newJob()
+	for range 2 {
+		_, err := db.ClaimDeletion(ctx, now)
+		require.NoError(t, err)
+	}
+	_, err := db.DB.ExecContext(ctx, `UPDATE deletion_jobs SET updated_at = $1 WHERE id = $2`,
+		now.Add(-2*DeletionLease), abandoned.ID)
+	require.NoError(t, err)
+
+	_, err = db.RequeueDeletions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobRunning, status(live.ID))
+	assert.Equal(t, models.JobQueued, status(abandoned.ID))
+
+	// Брошенная задача, которую не вернул в очередь перезапуск, берется повторно после истечения аренды
+	claimed, err := db.ClaimDeletion(ctx, now.Add(2*DeletionLease))
+	require.NoError(t, err)
+	assert.Contains(t, []string{live.ID, abandoned.ID}, claimed.ID)
+}
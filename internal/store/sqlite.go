@@ -0,0 +1,604 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go драйвер, не требует cgo
+
+	"github.com/JohnnyConstantin/urlshort/internal/config"
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// SQLiteStore хранилище ссылок в локальном файле SQLite. Схема повторяет семантику InitDB для PostgreSQL:
+// original_url уникален среди неудаленных ссылок, флаг is_deleted и время создания
+type SQLiteStore struct {
+	DB *sql.DB
+}
+
+// OpenSQLite открывает (и при необходимости создает) базу SQLite по пути path
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	// WAL позволяет читать параллельно с записью, busy_timeout - подождать блокировку вместо мгновенного SQLITE_BUSY.
+	// Транзакции Delete и ShortenBatch сначала читают, а потом пишут: повышение отложенной транзакции до записи
+	// не ждет busy_timeout, поэтому транзакции сразу берут блокировку записи (BEGIN IMMEDIATE)
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)" +
+		"&_txlock=immediate"
+
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть SQLite: %v", err)
+	}
+
+	if err = sqlDB.Ping(); err != nil {
+		return nil, fmt.Errorf("не удалось проверить подключение к SQLite: %v", err)
+	}
+
+	s := &SQLiteStore{DB: sqlDB}
+	if err = s.initDB(); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// initDB создает таблицу, если ее нет
+func (s *SQLiteStore) initDB() error {
+	query := `
+    CREATE TABLE IF NOT EXISTS urls (
+        id           INTEGER PRIMARY KEY AUTOINCREMENT,
+        uuid         TEXT,
+        short_url    TEXT UNIQUE NOT NULL,
+        original_url TEXT NOT NULL,
+        is_deleted   BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS idx_urls_uuid ON urls(uuid);
+    `
+	_, err := s.DB.ExecContext(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	// Колонки, появившиеся позже исходной схемы. Время хранится в unix-миллисекундах, чтобы сравнение шло по числам
+	if err = s.addColumn("urls", "expires_at", "INTEGER"); err != nil {
+		return err
+	}
+	if err = s.addColumn("urls", "title", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err = s.addColumn("urls", "interstitial", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+
+	// Оригинал уникален только среди живых ссылок: удаленный URL сокращается заново под новым short_url
+	if err = s.dropOriginalUnique(); err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(context.Background(),
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_original_url_active ON urls(original_url) WHERE is_deleted = FALSE`)
+	if err != nil {
+		return fmt.Errorf("failed to create original_url index: %w", err)
+	}
+
+	// Дневные счетчики переходов, прирост добавляется через ON CONFLICT
+	_, err = s.DB.ExecContext(context.Background(), `
+    CREATE TABLE IF NOT EXISTS link_clicks (
+        short_url TEXT NOT NULL,
+        day       TEXT NOT NULL,
+        clicks    INTEGER NOT NULL,
+        first_at  INTEGER NOT NULL,
+        last_at   INTEGER NOT NULL,
+        PRIMARY KEY (short_url, day)
+    )`)
+	if err != nil {
+		return fmt.Errorf("failed to create link_clicks: %w", err)
+	}
+
+	// Журнал посещений. Пустая строка bot - переход человека, иначе имя бота
+	_, err = s.DB.ExecContext(context.Background(), `
+    CREATE TABLE IF NOT EXISTS link_visits (
+        id         INTEGER PRIMARY KEY AUTOINCREMENT,
+        short_url  TEXT NOT NULL,
+        visited_at INTEGER NOT NULL,
+        referrer   TEXT NOT NULL DEFAULT '',
+        browser    TEXT NOT NULL DEFAULT '',
+        os         TEXT NOT NULL DEFAULT '',
+        device     TEXT NOT NULL DEFAULT '',
+        language   TEXT NOT NULL DEFAULT '',
+        ip         TEXT NOT NULL DEFAULT '',
+        bot        TEXT NOT NULL DEFAULT ''
+    );
+    CREATE INDEX IF NOT EXISTS idx_link_visits_short_url ON link_visits(short_url);
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create link_visits: %w", err)
+	}
+
+	// Очередь задач на удаление. short_urls - JSON массив, время в unix-миллисекундах
+	_, err = s.DB.ExecContext(context.Background(), `
+    CREATE TABLE IF NOT EXISTS deletion_jobs (
+        id         TEXT PRIMARY KEY,
+        user_id    TEXT NOT NULL,
+        short_urls TEXT NOT NULL,
+        status     TEXT NOT NULL,
+        error      TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        updated_at INTEGER NOT NULL,
+        deleted    INTEGER NOT NULL DEFAULT 0,
+        not_found  INTEGER NOT NULL DEFAULT 0,
+        not_owned  INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS idx_deletion_jobs_status ON deletion_jobs(status, created_at);
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create deletion_jobs: %w", err)
+	}
+
+	return nil
+}
+
+// dropOriginalUnique пересоздает таблицу urls без ограничения UNIQUE на original_url из исходной схемы.
+// SQLite не умеет удалять ограничения, поэтому данные копируются в новую таблицу
+func (s *SQLiteStore) dropOriginalUnique() error {
+	ctx := context.Background()
+
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, `
+        SELECT COUNT(*) > 0 FROM pragma_index_list('urls') AS l, pragma_index_info(l.name) AS i
+        WHERE l."unique" AND l.origin = 'u' AND i.name = 'original_url'`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect urls indexes: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	_, err = tx.ExecContext(ctx, `
+    CREATE TABLE urls_new (
+        id           INTEGER PRIMARY KEY AUTOINCREMENT,
+        uuid         TEXT,
+        short_url    TEXT UNIQUE NOT NULL,
+        original_url TEXT NOT NULL,
+        is_deleted   BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at   INTEGER,
+        title        TEXT NOT NULL DEFAULT '',
+        interstitial BOOLEAN NOT NULL DEFAULT FALSE
+    );
+    INSERT INTO urls_new (id, uuid, short_url, original_url, is_deleted, created_at, expires_at, title, interstitial)
+        SELECT id, uuid, short_url, original_url, is_deleted, created_at, expires_at, title, interstitial FROM urls;
+    DROP TABLE urls;
+    ALTER TABLE urls_new RENAME TO urls;
+    CREATE INDEX IF NOT EXISTS idx_urls_uuid ON urls(uuid);
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild urls without original_url constraint: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// addColumn добавляет колонку в существующую таблицу, если ее еще нет. SQLite не поддерживает ADD COLUMN IF NOT EXISTS
+func (s *SQLiteStore) addColumn(table, column, definition string) error {
+	var exists bool
+	err := s.DB.QueryRowContext(context.Background(),
+		`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = s.DB.ExecContext(context.Background(), `ALTER TABLE `+table+` ADD COLUMN `+column+` `+definition)
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// Shorten сохраняет URL в SQLite. При повторном сокращении того же URL возвращает существующий shortID и ErrConflict
+func (s *SQLiteStore) Shorten(ctx context.Context, userID string, req models.ShortenRequest) (string, error) {
+	return s.insert(ctx, s.DB, userID, req)
+}
+
+// ShortenBatch сохраняет несколько URL в одной транзакции
+func (s *SQLiteStore) ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	results := make([]ShortenResult, 0, len(reqs))
+	for _, req := range reqs {
+		shortID, err := s.insert(ctx, tx, userID, req)
+		if err != nil && !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		results = append(results, ShortenResult{ShortID: shortID, Conflict: err != nil})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// sqlExecutor общее подмножество *sql.DB и *sql.Tx
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insert вставляет ссылку, а при конфликте по original_url возвращает существующий shortID
+func (s *SQLiteStore) insert(ctx context.Context, db sqlExecutor, userID string, req models.ShortenRequest) (string, error) {
+	return generateID(userID, req, func(shortID string) (string, error) {
+		// DO NOTHING без указания колонки срабатывает и на original_url, и на занятый short_url
+		res, err := db.ExecContext(ctx,
+			`INSERT INTO urls (uuid, short_url, original_url, expires_at, title, interstitial)
+             VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			userID, shortID, req.URL, unixMilli(req.ExpiresAt), req.Title, req.Interstitial)
+		if err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return "", err
+		}
+		if inserted == 1 {
+			return shortID, nil
+		}
+
+		var existing string
+		err = db.QueryRowContext(ctx,
+			`SELECT short_url FROM urls WHERE original_url = ? AND is_deleted = FALSE`, req.URL).Scan(&existing)
+		switch {
+		case errors.Is(err, sql.ErrNoRows): // URL новый, значит занят сам shortID
+			return "", errIDTaken
+		case err != nil:
+			return "", fmt.Errorf("database error: %w", err)
+		}
+
+		return existing, ErrConflict
+	})
+}
+
+// Resolve получает запись по сокращенному URL
+func (s *SQLiteStore) Resolve(ctx context.Context, shortID string) (models.URLRecord, error) {
+	record := models.URLRecord{ShortURL: shortID}
+	var owner sql.NullString
+	var expiresAt sql.NullInt64
+	var createdAt sql.NullTime
+
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT uuid, original_url, is_deleted, expires_at, created_at, title, interstitial FROM urls WHERE short_url = ?`,
+		shortID,
+	).Scan(&owner, &record.OriginalURL, &record.DeletedFlag, &expiresAt, &createdAt, &record.Title, &record.Interstitial)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.URLRecord{}, ErrNotFound
+	case err != nil:
+		return models.URLRecord{}, err
+	}
+
+	record.UUID = owner.String
+	if expiresAt.Valid {
+		t := time.UnixMilli(expiresAt.Int64)
+		record.ExpiresAt = &t
+	}
+	if createdAt.Valid {
+		record.CreatedAt = &createdAt.Time
+	}
+	return record, nil
+}
+
+// ListByUser получает все неудаленные ссылки пользователя
+func (s *SQLiteStore) ListByUser(ctx context.Context, userID string) ([]models.URLResponse, error) {
+	var result []models.URLResponse
+
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT short_url, original_url FROM urls
+         WHERE uuid = ? AND is_deleted = FALSE AND (expires_at IS NULL OR expires_at > ?) ORDER BY id`,
+		userID, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database query error: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	for rows.Next() {
+		var record models.URLResponse
+		var shortURL string
+		if err := rows.Scan(&shortURL, &record.OriginalURL); err != nil {
+			return nil, err
+		}
+		record.ShortURL = config.Options.BaseAddress + "/" + shortURL
+		result = append(result, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return result, nil
+}
+
+// Delete помечает ссылки пользователя удаленными в одной транзакции: сначала раскладывает shortIDs по категориям,
+// затем помечает найденные одним запросом
+func (s *SQLiteStore) Delete(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	targets, result, err := classifyDeletion(userID, shortIDs, func(shortID string) (models.URLRecord, error) {
+		record := models.URLRecord{ShortURL: shortID}
+		var owner sql.NullString
+		err := tx.QueryRowContext(ctx, `SELECT uuid, is_deleted FROM urls WHERE short_url = ?`, shortID).
+			Scan(&owner, &record.DeletedFlag)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.URLRecord{}, ErrNotFound
+		}
+		record.UUID = owner.String
+		return record, err
+	})
+	if err != nil {
+		return DeleteResult{}, err
+	}
+
+	if len(targets) > 0 {
+		args := make([]any, 0, len(targets))
+		for _, record := range targets {
+			args = append(args, record.ShortURL)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(targets)), ",")
+
+		_, err = tx.ExecContext(ctx, `UPDATE urls SET is_deleted = TRUE WHERE short_url IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return DeleteResult{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return DeleteResult{}, err
+	}
+
+	return result, nil
+}
+
+// EnqueueDeletion сохраняет задачу на удаление в таблицу очереди
+func (s *SQLiteStore) EnqueueDeletion(ctx context.Context, job models.DeletionJob) error {
+	shortIDs, err := json.Marshal(job.ShortIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+        INSERT INTO deletion_jobs (id, user_id, short_urls, status, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?)`,
+		job.ID, job.UserID, string(shortIDs), job.Status, job.CreatedAt.UnixMilli(), job.UpdatedAt.UnixMilli())
+	return err
+}
+
+// ClaimDeletion берет самую старую задачу из очереди. Запись в SQLite сериализована, так что UPDATE атомарен
+func (s *SQLiteStore) ClaimDeletion(ctx context.Context, now time.Time) (models.DeletionJob, error) {
+	return scanSQLiteJob(s.DB.QueryRowContext(ctx, `
+        UPDATE deletion_jobs SET status = ?, updated_at = ?
+        WHERE id = (SELECT id FROM deletion_jobs WHERE status = ? ORDER BY created_at LIMIT 1)
+        RETURNING `+deletionJobColumns,
+		models.JobRunning, now.UnixMilli(), models.JobQueued,
+	))
+}
+
+// FinishDeletion сохраняет итоговый статус задачи
+func (s *SQLiteStore) FinishDeletion(ctx context.Context, job models.DeletionJob) error {
+	_, err := s.DB.ExecContext(ctx, `
+        UPDATE deletion_jobs SET status = ?, error = ?, updated_at = ?, deleted = ?, not_found = ?, not_owned = ?
+        WHERE id = ?`,
+		job.Status, job.Error, job.UpdatedAt.UnixMilli(), job.Deleted, job.NotFound, job.NotOwned, job.ID)
+	return err
+}
+
+// DeletionJob возвращает задачу на удаление по ID
+func (s *SQLiteStore) DeletionJob(ctx context.Context, id string) (models.DeletionJob, error) {
+	return scanSQLiteJob(s.DB.QueryRowContext(ctx, `SELECT `+deletionJobColumns+` FROM deletion_jobs WHERE id = ?`, id))
+}
+
+// scanSQLiteJob читает задачу из строки с колонками deletionJobColumns
+func scanSQLiteJob(row *sql.Row) (models.DeletionJob, error) {
+	var job models.DeletionJob
+	var shortIDs string
+	var createdAt, updatedAt int64
+
+	err := row.Scan(&job.ID, &job.UserID, &shortIDs, &job.Status, &job.Error, &createdAt, &updatedAt,
+		&job.Deleted, &job.NotFound, &job.NotOwned)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return models.DeletionJob{}, ErrNotFound
+	case err != nil:
+		return models.DeletionJob{}, err
+	}
+
+	if err = json.Unmarshal([]byte(shortIDs), &job.ShortIDs); err != nil {
+		return models.DeletionJob{}, fmt.Errorf("broken deletion job %s: %w", job.ID, err)
+	}
+	job.CreatedAt, job.UpdatedAt = time.UnixMilli(createdAt).UTC(), time.UnixMilli(updatedAt).UTC()
+	return job, nil
+}
+
+// RequeueDeletions возвращает в очередь задачи, прерванные остановкой сервера
+func (s *SQLiteStore) RequeueDeletions(ctx context.Context) (int, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE deletion_jobs SET status = ? WHERE status = ?`, models.JobQueued, models.JobRunning)
+	if err != nil {
+		return 0, err
+	}
+
+	requeued, err := res.RowsAffected()
+	return int(requeued), err
+}
+
+// ReapExpired помечает истекшие ссылки удаленными
+func (s *SQLiteStore) ReapExpired(ctx context.Context, now time.Time) (int, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE urls SET is_deleted = TRUE WHERE expires_at <= ? AND is_deleted = FALSE`, now.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+
+	reaped, err := res.RowsAffected()
+	return int(reaped), err
+}
+
+// RecordClicks добавляет прирост дневных счетчиков переходов в одной транзакции
+func (s *SQLiteStore) RecordClicks(ctx context.Context, clicks []Click) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	for shortID, daily := range aggregateClicks(clicks) {
+		for _, day := range daily {
+			_, err = tx.ExecContext(ctx, `
+            INSERT INTO link_clicks (short_url, day, clicks, first_at, last_at) VALUES (?, ?, ?, ?, ?)
+            ON CONFLICT (short_url, day) DO UPDATE SET
+                clicks   = clicks + excluded.clicks,
+                first_at = min(first_at, excluded.first_at),
+                last_at  = max(last_at, excluded.last_at)
+            `, shortID, day.Date, day.Clicks, day.FirstClick.UnixMilli(), day.LastClick.UnixMilli())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ClickStats возвращает статистику переходов по ссылке
+func (s *SQLiteStore) ClickStats(ctx context.Context, shortID string) (models.LinkStats, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT day, clicks, first_at, last_at FROM link_clicks WHERE short_url = ? ORDER BY day`,
+		shortID,
+	)
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf("database query error: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	var daily []models.DailyClicks
+	for rows.Next() {
+		var day models.DailyClicks
+		var first, last int64
+		if err = rows.Scan(&day.Date, &day.Clicks, &first, &last); err != nil {
+			return models.LinkStats{}, err
+		}
+		day.FirstClick, day.LastClick = time.UnixMilli(first).UTC(), time.UnixMilli(last).UTC()
+		daily = append(daily, day)
+	}
+	if err = rows.Err(); err != nil {
+		return models.LinkStats{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return buildStats(shortID, daily), nil
+}
+
+// RecordVisits сохраняет события перехода в одной транзакции
+func (s *SQLiteStore) RecordVisits(ctx context.Context, visits []models.Visit) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback() // После Commit возвращает ErrTxDone, это ожидаемо
+	}(tx)
+
+	for _, v := range visits {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO link_visits (short_url, visited_at, referrer, browser, os, device, language, ip, bot)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			v.ShortURL, v.At.UnixMilli(), v.Referrer, v.Browser, v.OS, v.Device, v.Language, v.IP, v.Bot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// VisitStats считает разбивку посещений ссылки одним запросом
+func (s *SQLiteStore) VisitStats(ctx context.Context, shortID string) (models.VisitStats, error) {
+	rows, err := s.DB.QueryContext(ctx, visitBreakdownQuery("?1"), shortID) // ?1 - позиционный параметр, повторяемый в UNION
+	if err != nil {
+		return models.VisitStats{}, fmt.Errorf("database query error: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	stats := newVisitStats(shortID)
+	for rows.Next() {
+		var dimension, value string
+		var n int64
+		if err = rows.Scan(&dimension, &value, &n); err != nil {
+			return models.VisitStats{}, err
+		}
+		addBreakdown(&stats, dimension, value, n)
+	}
+	if err = rows.Err(); err != nil {
+		return models.VisitStats{}, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Ping проверяет доступность базы
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// Close закрывает базу
+func (s *SQLiteStore) Close() error {
+	return s.DB.Close()
+}
+
+// unixMilli переводит необязательное время в значение колонки: NULL либо unix-миллисекунды
+func unixMilli(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixMilli()
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// TestSQLiteStore проверяет основной сценарий работы с SQLite: сокращение, конфликт, батч, список и удаление
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := OpenSQLite(path)
+	require.NoError(t, err)
+
+	shortID, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	// Повторное сокращение возвращает тот же shortID с ErrConflict
+	again, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, shortID, again)
+
+	results, err := s.ShortenBatch(ctx, "user", []models.ShortenRequest{
+		{URL: "https://example.com"},
+		{URL: "https://example.com/batch"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, ShortenResult{ShortID: shortID, Conflict: true}, results[0])
+	assert.False(t, results[1].Conflict)
+
+	// Alias используется как есть, повторно занять его нельзя
+	alias, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/alias", Alias: "my-alias"})
+	require.NoError(t, err)
+	assert.Equal(t, "my-alias", alias)
+	_, err = s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/other", Alias: "my-alias"})
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	urls, err := s.ListByUser(ctx, "user")
+	require.NoError(t, err)
+	assert.Len(t, urls, 3)
+
+	// Чужой пользователь не может удалить ссылку
+	result, err := s.Delete(ctx, "stranger", []string{shortID, "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, DeleteResult{NotFound: 1, NotOwned: 1}, result)
+	record, err := s.Resolve(ctx, shortID)
+	require.NoError(t, err)
+	assert.False(t, record.DeletedFlag)
+
+	// Повтор в запросе и повторное удаление считаются удаленными один раз
+	result, err = s.Delete(ctx, "user", []string{shortID, shortID})
+	require.NoError(t, err)
+	assert.Equal(t, DeleteResult{Deleted: 1}, result)
+	result, err = s.Delete(ctx, "user", []string{shortID})
+	require.NoError(t, err)
+	assert.Equal(t, DeleteResult{Deleted: 1}, result)
+	require.NoError(t, s.Close())
+
+	// Данные переживают переоткрытие
+	s, err = OpenSQLite(path)
+	require.NoError(t, err)
+	defer func(s *SQLiteStore) {
+		require.NoError(t, s.Close())
+	}(s)
+
+	record, err = s.Resolve(ctx, shortID)
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+	assert.Equal(t, "user", record.UUID)
+
+	_, err = s.Resolve(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Удаленный URL сокращается заново под новым идентификатором, как в остальных хранилищах
+	again, err = s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.NotEqual(t, shortID, again)
+	results, err = s.ShortenBatch(ctx, "user", []models.ShortenRequest{{URL: "https://example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, []ShortenResult{{ShortID: again, Conflict: true}}, results)
+}
+
+// TestSQLiteMigrateOriginalUnique проверяет, что база со старой схемой теряет UNIQUE на original_url, сохранив данные
+func TestSQLiteMigrateOriginalUnique(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	old, err := sql.Open("sqlite", "file:"+path)
+	require.NoError(t, err)
+	_, err = old.Exec(`
+    CREATE TABLE urls (
+        id           INTEGER PRIMARY KEY AUTOINCREMENT,
+        uuid         TEXT,
+        short_url    TEXT UNIQUE NOT NULL,
+        original_url TEXT UNIQUE NOT NULL,
+        is_deleted   BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+    INSERT INTO urls (uuid, short_url, original_url, is_deleted) VALUES
+        ('user', 'deleted', 'https://example.com/deleted', TRUE),
+        ('user', 'live', 'https://example.com/live', FALSE);
+    `)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	s, err := OpenSQLite(path)
+	require.NoError(t, err)
+	defer func(s *SQLiteStore) {
+		require.NoError(t, s.Close())
+	}(s)
+
+	record, err := s.Resolve(ctx, "deleted")
+	require.NoError(t, err)
+	assert.True(t, record.DeletedFlag)
+
+	shortID, err := s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/deleted"})
+	require.NoError(t, err)
+	assert.NotEqual(t, "deleted", shortID)
+
+	shortID, err = s.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/live"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, "live", shortID)
+}
+
+// TestSQLiteConcurrentWrites проверяет, что параллельные удаления и батчи ждут блокировку, а не падают с SQLITE_BUSY:
+// обе операции сначала читают, и отложенная транзакция не смогла бы повысить блокировку до записи
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	const workers, rounds = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userID := fmt.Sprintf("user-%d", w)
+			for i := range rounds {
+				reqs := make([]models.ShortenRequest, 0, 10)
+				for j := range 10 {
+					reqs = append(reqs, models.ShortenRequest{URL: fmt.Sprintf("https://example.com/%d/%d/%d", w, i, j)})
+				}
+				results, err := s.ShortenBatch(ctx, userID, reqs)
+				if err != nil {
+					errs <- err
+					continue
+				}
+
+				shortIDs := make([]string, 0, len(results))
+				for _, result := range results {
+					shortIDs = append(shortIDs, result.ShortID)
+				}
+				if _, err = s.Delete(ctx, userID, shortIDs); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
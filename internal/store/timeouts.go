@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Таймауты операций по умолчанию
+const (
+	DefaultReadTimeout  = 2 * time.Second // Чтение ссылок, списков и статистики
+	DefaultWriteTimeout = 5 * time.Second // Вставки, удаления, запись переходов и обслуживание очереди
+)
+
+// ErrTimeout операция с хранилищем не уложилась в отведенное время. Оборачивает исходную ошибку драйвера
+var ErrTimeout = errors.New("storage operation timed out")
+
+// Timeouts ограничения времени операций с хранилищем. 0 - без ограничения
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// TimeoutRepository Repository, ограничивающий каждую операцию таймаутом. Контекст операции наследует контекст
+// вызывающего, поэтому запрос отменяется и при отключении клиента, и по таймауту. Хранилища в памяти и в файле
+// контекст не проверяют, для них таймаут фактически не действует
+type TimeoutRepository struct {
+	Repository
+	timeouts Timeouts
+}
+
+// NewTimeoutRepository оборачивает repo таймаутами
+func NewTimeoutRepository(repo Repository, timeouts Timeouts) *TimeoutRepository {
+	return &TimeoutRepository{Repository: repo, timeouts: timeouts}
+}
+
+// bounded выполняет op с таймаутом timeout. Если истек именно он, а не контекст вызывающего, ошибка оборачивается в ErrTimeout
+func bounded[T any](ctx context.Context, timeout time.Duration, op func(ctx context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return op(ctx)
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := op(opCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %w", ErrTimeout, timeout, err)
+	}
+	return result, err
+}
+
+// boundedErr bounded для операций без результата
+func boundedErr(ctx context.Context, timeout time.Duration, op func(ctx context.Context) error) error {
+	_, err := bounded(ctx, timeout, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, op(ctx)
+	})
+	return err
+}
+
+// Shorten сохраняет URL с таймаутом записи
+func (r *TimeoutRepository) Shorten(ctx context.Context, userID string, req models.ShortenRequest) (string, error) {
+	return bounded(ctx, r.timeouts.Write, func(ctx context.Context) (string, error) {
+		return r.Repository.Shorten(ctx, userID, req)
+	})
+}
+
+// ShortenBatch сохраняет батч с таймаутом записи
+func (r *TimeoutRepository) ShortenBatch(ctx context.Context, userID string, reqs []models.ShortenRequest) ([]ShortenResult, error) {
+	return bounded(ctx, r.timeouts.Write, func(ctx context.Context) ([]ShortenResult, error) {
+		return r.Repository.ShortenBatch(ctx, userID, reqs)
+	})
+}
+
+// Resolve читает ссылку с таймаутом чтения
+func (r *TimeoutRepository) Resolve(ctx context.Context, shortID string) (models.URLRecord, error) {
+	return bounded(ctx, r.timeouts.Read, func(ctx context.Context) (models.URLRecord, error) {
+		return r.Repository.Resolve(ctx, shortID)
+	})
+}
+
+// ListByUser читает ссылки пользователя с таймаутом чтения
+func (r *TimeoutRepository) ListByUser(ctx context.Context, userID string) ([]models.URLResponse, error) {
+	return bounded(ctx, r.timeouts.Read, func(ctx context.Context) ([]models.URLResponse, error) {
+		return r.Repository.ListByUser(ctx, userID)
+	})
+}
+
+// Delete удаляет ссылки с таймаутом записи
+func (r *TimeoutRepository) Delete(ctx context.Context, userID string, shortIDs []string) (DeleteResult, error) {
+	return bounded(ctx, r.timeouts.Write, func(ctx context.Context) (DeleteResult, error) {
+		return r.Repository.Delete(ctx, userID, shortIDs)
+	})
+}
+
+// EnqueueDeletion сохраняет задачу с таймаутом записи
+func (r *TimeoutRepository) EnqueueDeletion(ctx context.Context, job models.DeletionJob) error {
+	return boundedErr(ctx, r.timeouts.Write, func(ctx context.Context) error {
+		return r.Repository.EnqueueDeletion(ctx, job)
+	})
+}
+
+// ClaimDeletion берет задачу с таймаутом записи
+func (r *TimeoutRepository) ClaimDeletion(ctx context.Context, now time.Time) (models.DeletionJob, error) {
+	return bounded(ctx, r.timeouts.Write, func(ctx context.Context) (models.DeletionJob, error) {
+		return r.Repository.ClaimDeletion(ctx, now)
+	})
+}
+
+// FinishDeletion сохраняет статус задачи с таймаутом записи
+func (r *TimeoutRepository) FinishDeletion(ctx context.Context, job models.DeletionJob) error {
+	return boundedErr(ctx, r.timeouts.Write, func(ctx context.Context) error {
+		return r.Repository.FinishDeletion(ctx, job)
+	})
+}
+
+// RequeueDeletions возвращает задачи в очередь с таймаутом записи
+func (r *TimeoutRepository) RequeueDeletions(ctx context.Context) (int, error) {
+	return bounded(ctx, r.timeouts.Write, r.Repository.RequeueDeletions)
+}
+
+// DeletionJob читает задачу с таймаутом чтения
+func (r *TimeoutRepository) DeletionJob(ctx context.Context, id string) (models.DeletionJob, error) {
+	return bounded(ctx, r.timeouts.Read, func(ctx context.Context) (models.DeletionJob, error) {
+		return r.Repository.DeletionJob(ctx, id)
+	})
+}
+
+// ReapExpired чистит истекшие ссылки с таймаутом записи
+func (r *TimeoutRepository) ReapExpired(ctx context.Context, now time.Time) (int, error) {
+	return bounded(ctx, r.timeouts.Write, func(ctx context.Context) (int, error) {
+		return r.Repository.ReapExpired(ctx, now)
+	})
+}
+
+// RecordClicks записывает переходы с таймаутом записи
+func (r *TimeoutRepository) RecordClicks(ctx context.Context, clicks []Click) error {
+	return boundedErr(ctx, r.timeouts.Write, func(ctx context.Context) error {
+		return r.Repository.RecordClicks(ctx, clicks)
+	})
+}
+
+// ClickStats читает статистику с таймаутом чтения
+func (r *TimeoutRepository) ClickStats(ctx context.Context, shortID string) (models.LinkStats, error) {
+	return bounded(ctx, r.timeouts.Read, func(ctx context.Context) (models.LinkStats, error) {
+		return r.Repository.ClickStats(ctx, shortID)
+	})
+}
+
+// RecordVisits записывает события переходов с таймаутом записи
+func (r *TimeoutRepository) RecordVisits(ctx context.Context, visits []models.Visit) error {
+	return boundedErr(ctx, r.timeouts.Write, func(ctx context.Context) error {
+		return r.Repository.RecordVisits(ctx, visits)
+	})
+}
+
+// VisitStats читает разбивку посещений с таймаутом чтения
+func (r *TimeoutRepository) VisitStats(ctx context.Context, shortID string) (models.VisitStats, error) {
+	return bounded(ctx, r.timeouts.Read, func(ctx context.Context) (models.VisitStats, error) {
+		return r.Repository.VisitStats(ctx, shortID)
+	})
+}
+
+// Ping проверяет хранилище с таймаутом чтения
+func (r *TimeoutRepository) Ping(ctx context.Context) error {
+	return boundedErr(ctx, r.timeouts.Read, r.Repository.Ping)
+}
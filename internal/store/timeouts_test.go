@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// blockingRepo зависает в Resolve до отмены контекста, как долгий запрос к базе
+type blockingRepo struct {
+	Repository
+}
+
+func (r *blockingRepo) Resolve(ctx context.Context, _ string) (models.URLRecord, error) {
+	<-ctx.Done()
+	return models.URLRecord{}, ctx.Err()
+}
+
+// TestTimeoutRepository проверяет, что операция прерывается по таймауту и что отмена вызывающим не считается таймаутом
+func TestTimeoutRepository(t *testing.T) {
+	repo := NewTimeoutRepository(&blockingRepo{Repository: NewMemoryStore()},
+		Timeouts{Read: 20 * time.Millisecond, Write: time.Second})
+
+	started := time.Now()
+	_, err := repo.Resolve(context.Background(), "abc")
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = repo.Resolve(ctx, "abc")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrTimeout)
+
+	// Остальные операции проходят как обычно
+	shortID, err := repo.Shorten(context.Background(), "user", models.ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, shortID)
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// Измерения разбивки переходов. Используются и в SQL запросах, и при агрегации в памяти
+const (
+	visitReferrer = "referrer"
+	visitBrowser  = "browser"
+	visitOS       = "os"
+	visitDevice   = "device"
+	visitLanguage = "language"
+	visitBot      = "bot"
+)
+
+// Подписи для пустых значений в разбивке
+const (
+	directReferrer  = "(direct)"
+	unknownLanguage = "(unknown)"
+)
+
+// newVisitStats пустая разбивка с инициализированными map, чтобы в JSON были {} вместо null
+func newVisitStats(shortID string) models.VisitStats {
+	return models.VisitStats{
+		ShortURL:  shortID,
+		Referrers: make(map[string]int64),
+		Browsers:  make(map[string]int64),
+		OS:        make(map[string]int64),
+		Devices:   make(map[string]int64),
+		Languages: make(map[string]int64),
+		Bots:      make(map[string]int64),
+	}
+}
+
+// addBreakdown прибавляет n переходов к значению измерения. Общие счетчики людей и ботов
+// считаются по измерениям browser и bot, которые есть у каждого перехода ровно по одному
+func addBreakdown(stats *models.VisitStats, dimension, value string, n int64) {
+	switch dimension {
+	case visitReferrer:
+		if value == "" {
+			value = directReferrer
+		}
+		stats.Referrers[value] += n
+	case visitBrowser:
+		stats.Browsers[value] += n
+		stats.HumanVisits += n
+	case visitOS:
+		stats.OS[value] += n
+	case visitDevice:
+		stats.Devices[value] += n
+	case visitLanguage:
+		if value == "" {
+			value = unknownLanguage
+		}
+		stats.Languages[value] += n
+	case visitBot:
+		stats.Bots[value] += n
+		stats.BotVisits += n
+	}
+}
+
+// addVisit учитывает один переход. Переходы ботов попадают только в разбивку по ботам
+func addVisit(stats *models.VisitStats, visit models.Visit) {
+	if visit.Bot != "" {
+		addBreakdown(stats, visitBot, visit.Bot, 1)
+		return
+	}
+	addBreakdown(stats, visitReferrer, visit.Referrer, 1)
+	addBreakdown(stats, visitBrowser, visit.Browser, 1)
+	addBreakdown(stats, visitOS, visit.OS, 1)
+	addBreakdown(stats, visitDevice, visit.Device, 1)
+	addBreakdown(stats, visitLanguage, visit.Language, 1)
+}
+
+// mergeVisitStats прибавляет разбивку src к dst
+func mergeVisitStats(dst *models.VisitStats, src models.VisitStats) {
+	merge := func(dimension string, values map[string]int64) {
+		for value, n := range values {
+			addBreakdown(dst, dimension, value, n)
+		}
+	}
+	merge(visitReferrer, src.Referrers)
+	merge(visitBrowser, src.Browsers)
+	merge(visitOS, src.OS)
+	merge(visitDevice, src.Devices)
+	merge(visitLanguage, src.Languages)
+	merge(visitBot, src.Bots)
+}
+
+// aggregateVisits сворачивает пачку переходов в разбивки по каждой ссылке
+func aggregateVisits(visits []models.Visit) map[string]models.VisitStats {
+	result := make(map[string]models.VisitStats)
+	for _, visit := range visits {
+		stats, ok := result[visit.ShortURL]
+		if !ok {
+			stats = newVisitStats(visit.ShortURL)
+		}
+		addVisit(&stats, visit)
+		result[visit.ShortURL] = stats
+	}
+	return result
+}
+
+// visitBreakdownQuery одним запросом считает разбивку по всем измерениям для таблицы link_visits.
+// param - плейсхолдер shortID в диалекте СУБД, он повторяется в каждой части UNION
+func visitBreakdownQuery(param string) string {
+	human := func(dimension, column string) string {
+		return `SELECT '` + dimension + `', ` + column + `, COUNT(*) FROM link_visits
+            WHERE short_url = ` + param + ` AND bot = '' GROUP BY ` + column
+	}
+
+	return human(visitReferrer, "referrer") +
+		"\nUNION ALL " + human(visitBrowser, "browser") +
+		"\nUNION ALL " + human(visitOS, "os") +
+		"\nUNION ALL " + human(visitDevice, "device") +
+		"\nUNION ALL " + human(visitLanguage, "language") +
+		"\nUNION ALL SELECT '" + visitBot + "', bot, COUNT(*) FROM link_visits WHERE short_url = " + param + " AND bot <> '' GROUP BY bot"
+}
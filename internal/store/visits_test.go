@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/JohnnyConstantin/urlshort/models"
+)
+
+// TestVisitStats проверяет разбивку посещений и исключение ботов во всех встроенных хранилищах
+func TestVisitStats(t *testing.T) {
+	ctx := context.Background()
+	sugar := *zaptest.NewLogger(t).Sugar()
+	dir := t.TempDir()
+
+	fileStore, err := OpenFileStore(filepath.Join(dir, "urls.jsonl"), sugar)
+	require.NoError(t, err)
+	sqliteStore, err := OpenSQLite(filepath.Join(dir, "urls.db"))
+	require.NoError(t, err)
+	boltStore, err := OpenBolt(filepath.Join(dir, "urls.bolt"), "", sugar)
+	require.NoError(t, err)
+
+	repos := map[string]Repository{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+		"sqlite": sqliteStore,
+		"bolt":   boltStore,
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			defer func(repo Repository) {
+				require.NoError(t, repo.Close())
+			}(repo)
+
+			shortID, err := repo.Shorten(ctx, "user", models.ShortenRequest{URL: "https://example.com/" + name})
+			require.NoError(t, err)
+
+			now := time.Now()
+			require.NoError(t, repo.RecordVisits(ctx, []models.Visit{
+				{ShortURL: shortID, At: now, Referrer: "news.ycombinator.com", Browser: "Firefox", OS: "Linux", Device: "desktop", Language: "en"},
+				{ShortURL: shortID, At: now, Browser: "Safari", OS: "iOS", Device: "mobile", Language: "ru"},
+				{ShortURL: shortID, At: now, Browser: "Other", OS: "Other", Device: "bot", Bot: "Googlebot"},
+			}))
+			require.NoError(t, repo.RecordVisits(ctx, []models.Visit{
+				{ShortURL: shortID, At: now, Referrer: "news.ycombinator.com", Browser: "Firefox", OS: "Windows", Device: "desktop"},
+			}))
+
+			stats, err := repo.VisitStats(ctx, shortID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), stats.HumanVisits)
+			assert.Equal(t, int64(1), stats.BotVisits)
+			assert.Equal(t, map[string]int64{"news.ycombinator.com": 2, directReferrer: 1}, stats.Referrers)
+			assert.Equal(t, map[string]int64{"Firefox": 2, "Safari": 1}, stats.Browsers)
+			assert.Equal(t, map[string]int64{"Linux": 1, "iOS": 1, "Windows": 1}, stats.OS)
+			assert.Equal(t, map[string]int64{"desktop": 2, "mobile": 1}, stats.Devices)
+			assert.Equal(t, map[string]int64{"en": 1, "ru": 1, unknownLanguage: 1}, stats.Languages)
+			assert.Equal(t, map[string]int64{"Googlebot": 1}, stats.Bots)
+
+			empty, err := repo.VisitStats(ctx, "missing")
+			require.NoError(t, err)
+			assert.Zero(t, empty.HumanVisits)
+			assert.NotNil(t, empty.Browsers)
+		})
+	}
+}
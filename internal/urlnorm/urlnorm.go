@@ -0,0 +1,186 @@
+// Package urlnorm проверяет и нормализует URL перед сокращением: схема из разрешенного списка, хост в нижнем регистре
+// и ASCII (IDN в Punycode), без порта по умолчанию и, по желанию, без utm_* параметров. Одинаковые по смыслу URL
+// после нормализации совпадают побайтно, поэтому дедупликация хранилища их склеивает
+package urlnorm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength максимальная длина URL. Больше не принимают многие браузеры и прокси
+const MaxLength = 2048
+
+// DefaultSchemes схемы, разрешенные по умолчанию
+//
+//nolint:gochecknoglobals
+var DefaultSchemes = []string{"http", "https"}
+
+// ErrInvalid URL не прошел проверку. Причина добавляется к тексту ошибки и отдается клиенту
+var ErrInvalid = errors.New("invalid url")
+
+// defaultPorts порты по умолчанию, которые убираются из URL
+//
+//nolint:gochecknoglobals
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// schemeRe допустимое имя схемы по RFC 3986
+var schemeRe = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// labelRe метка домена после перевода в ASCII. Подчеркивание допускается: оно встречается в реальных поддоменах
+var labelRe = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9_])?$`)
+
+// Normalizer проверяет и нормализует URL по настройкам сервиса
+type Normalizer struct {
+	schemes       map[string]bool
+	stripTracking bool
+}
+
+// New создает нормализатор. Пустой schemes означает DefaultSchemes. stripTracking включает удаление utm_* параметров
+func New(schemes []string, stripTracking bool) (*Normalizer, error) {
+	if len(schemes) == 0 {
+		schemes = DefaultSchemes
+	}
+
+	allowed := make(map[string]bool, len(schemes))
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if !schemeRe.MatchString(scheme) {
+			return nil, fmt.Errorf("invalid scheme %q", scheme)
+		}
+		allowed[scheme] = true
+	}
+
+	return &Normalizer{schemes: allowed, stripTracking: stripTracking}, nil
+}
+
+// Normalize проверяет raw и возвращает его нормализованную форму. Ошибка оборачивает ErrInvalid и объясняет причину
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: url is empty", ErrInvalid)
+	}
+	if len(raw) > MaxLength {
+		return "", fmt.Errorf("%w: url is longer than %d characters", ErrInvalid, MaxLength)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed url", ErrInvalid)
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("%w: url must be absolute, e.g. https://example.com", ErrInvalid)
+	}
+	if !n.schemes[u.Scheme] {
+		return "", fmt.Errorf("%w: scheme %q is not allowed", ErrInvalid, u.Scheme)
+	}
+
+	// Схемы вида mailto: не имеют хоста, веб-схемам он обязателен
+	_, web := defaultPorts[u.Scheme]
+	if u.Host == "" && (web || u.Opaque == "") {
+		return "", fmt.Errorf("%w: url has no host", ErrInvalid)
+	}
+
+	if u.Host != "" {
+		if u.Host, err = normalizeHost(u.Scheme, u.Hostname(), u.Port()); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	if n.stripTracking {
+		u.RawQuery = stripTrackingParams(u.RawQuery)
+		if u.RawQuery == "" {
+			u.ForceQuery = false
+		}
+	}
+
+	return u.String(), nil
+}
+
+// normalizeHost приводит хост к нижнему регистру и ASCII и убирает порт по умолчанию для схемы
+func normalizeHost(scheme, hostname, port string) (string, error) {
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return "", fmt.Errorf("invalid port %q", port)
+		}
+		if defaultPorts[scheme] == port {
+			port = ""
+		}
+	}
+
+	var host string
+	if ip := net.ParseIP(hostname); ip != nil {
+		host = strings.ToLower(hostname)
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	} else {
+		var err error
+		if host, err = Domain(hostname); err != nil {
+			return "", err
+		}
+	}
+
+	if port != "" {
+		return host + ":" + port, nil
+	}
+	return host, nil
+}
+
+// Domain приводит доменное имя к той же форме, что и хост нормализованного URL: нижний регистр, ASCII-форма IDNA,
+// без точки в конце
+func Domain(hostname string) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(norm.NFC.String(hostname)), ".")
+	if domain == "" {
+		return "", errors.New("url has no host")
+	}
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		ascii, err := toASCIILabel(label)
+		if err != nil || len(ascii) > 63 || !labelRe.MatchString(ascii) {
+			return "", fmt.Errorf("invalid host %q", hostname)
+		}
+		labels[i] = ascii
+	}
+
+	domain = strings.Join(labels, ".")
+	if len(domain) > 253 {
+		return "", fmt.Errorf("host %q is too long", hostname)
+	}
+	return domain, nil
+}
+
+// stripTrackingParams убирает из строки запроса utm_* параметры, сохраняя порядок и кодирование остальных
+func stripTrackingParams(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	kept := make([]string, 0, strings.Count(rawQuery, "&")+1)
+	for _, param := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,98 @@
+package urlnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	n, err := New(nil, true)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "Unchanged", raw: "https://example.com", want: "https://example.com"},
+		{name: "Surrounding spaces", raw: "  https://example.com/a  ", want: "https://example.com/a"},
+		{name: "Case", raw: "HTTPS://Example.COM/Path", want: "https://example.com/Path"},
+		{name: "Default port", raw: "http://example.com:80/a", want: "http://example.com/a"},
+		{name: "Custom port", raw: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{name: "Trailing dot", raw: "https://example.com./", want: "https://example.com/"},
+		{name: "IDN", raw: "https://Пример.испытание/", want: "https://xn--e1afmkfd.xn--80akhbyknj4f/"},
+		{name: "Mixed label", raw: "http://bücher.de", want: "http://xn--bcher-kva.de"},
+		{name: "IPv6", raw: "http://[2001:DB8::1]:80/", want: "http://[2001:db8::1]/"},
+		{name: "Tracking params", raw: "https://example.com/?utm_source=x&id=1&UTM_Medium=y&b=%20", want: "https://example.com/?id=1&b=%20"},
+		{name: "Only tracking params", raw: "https://example.com/a?utm_source=x#top", want: "https://example.com/a#top"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := n.Normalize(tt.raw)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	n, err := New(nil, false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		raw    string
+		reason string
+	}{
+		{raw: "", reason: "empty"},
+		{raw: "example.com", reason: "absolute"},
+		{raw: "/relative/path", reason: "absolute"},
+		{raw: "javascript:alert(1)", reason: `scheme "javascript"`},
+		{raw: "data:text/html,<script>", reason: `scheme "data"`},
+		{raw: "ftp://example.com", reason: `scheme "ftp"`},
+		{raw: "http://", reason: "no host"},
+		{raw: "http:example.com", reason: "no host"},
+		{raw: "http://exa mple.com", reason: ""},
+		{raw: "http://example.com:99999", reason: "port"},
+		{raw: "http://-bad-.com", reason: "invalid host"},
+		{raw: "https://example.com/" + string(make([]byte, MaxLength)), reason: "longer"},
+	}
+	for _, tt := range tests {
+		_, err := n.Normalize(tt.raw)
+		require.ErrorIs(t, err, ErrInvalid, tt.raw)
+		assert.Contains(t, err.Error(), tt.reason)
+	}
+
+	// Без удаления трекинга utm_* остаются
+	got, err := n.Normalize("https://example.com/?utm_source=x")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/?utm_source=x", got)
+}
+
+func TestSchemeAllowList(t *testing.T) {
+	n, err := New([]string{"HTTPS", "mailto"}, false)
+	require.NoError(t, err)
+
+	_, err = n.Normalize("http://example.com")
+	assert.ErrorIs(t, err, ErrInvalid)
+	got, err := n.Normalize("mailto:user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "mailto:user@example.com", got)
+
+	_, err = New([]string{"1http"}, false)
+	assert.Error(t, err)
+}
+
+func TestEncodePunycode(t *testing.T) {
+	for label, want := range map[string]string{
+		"münchen": "mnchen-3ya",
+		"пример":  "e1afmkfd",
+		"bücher":  "bcher-kva",
+		"例え":      "r8jz45g",
+	} {
+		got, err := encodePunycode(label)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, label)
+	}
+}
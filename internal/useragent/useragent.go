@@ -0,0 +1,149 @@
+// Package useragent грубо классифицирует заголовок User-Agent: браузер, ОС, класс устройства и поисковые/превью боты.
+// Точность на уровне "для статистики переходов", полноценный парсер с базой сигнатур здесь не нужен
+package useragent
+
+import "strings"
+
+// Классы устройств
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other" // Пустой или нераспознанный User-Agent
+)
+
+// Other значение для нераспознанного браузера или ОС
+const Other = "Other"
+
+// Info результат разбора User-Agent
+type Info struct {
+	Browser string
+	OS      string
+	Device  string
+	Bot     string // Имя бота, пусто для людей
+}
+
+// IsBot является ли клиент ботом
+func (i Info) IsBot() bool {
+	return i.Bot != ""
+}
+
+// signature подстрока в User-Agent (в нижнем регистре) и соответствующее ей имя
+type signature struct {
+	token string
+	name  string
+}
+
+// Известные краулеры, превью мессенджеров и скриптовые клиенты. Порядок важен: проверяются сверху вниз
+//
+//nolint:gochecknoglobals
+var bots = []signature{
+	{"googlebot", "Googlebot"},
+	{"bingbot", "Bingbot"},
+	{"yandexbot", "YandexBot"},
+	{"duckduckbot", "DuckDuckBot"},
+	{"baiduspider", "Baiduspider"},
+	{"applebot", "Applebot"},
+	{"telegrambot", "TelegramBot"}, // Раньше Twitterbot: Telegram представляется "TelegramBot (like TwitterBot)"
+	{"facebookexternalhit", "Facebook"},
+	{"facebot", "Facebook"},
+	{"twitterbot", "Twitterbot"},
+	{"slack", "Slackbot"},
+	{"whatsapp", "WhatsApp"},
+	{"discordbot", "Discordbot"},
+	{"linkedinbot", "LinkedInBot"},
+	{"skypeuripreview", "Skype"},
+	{"pinterest", "Pinterest"},
+	{"redditbot", "Redditbot"},
+	{"vkshare", "VK"},
+	{"embedly", "Embedly"},
+	{"ahrefsbot", "AhrefsBot"},
+	{"semrushbot", "SemrushBot"},
+	{"petalbot", "PetalBot"},
+	{"headlesschrome", "HeadlessChrome"},
+	{"curl/", "Script"},
+	{"wget/", "Script"},
+	{"python-requests", "Script"},
+	{"go-http-client", "Script"},
+	// Общие признаки - в самом конце, после именованных ботов
+	{"bot", "Other bot"},
+	{"crawl", "Other bot"},
+	{"spider", "Other bot"},
+	{"preview", "Other bot"},
+}
+
+// Браузеры. Chromium-based браузеры идут раньше Chrome, Chrome - раньше Safari: их User-Agent содержит оба токена
+//
+//nolint:gochecknoglobals
+var browsers = []signature{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"yabrowser", "Yandex"},
+	{"samsungbrowser", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios", "Firefox"},
+	{"crios", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+	{"msie", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+}
+
+// ОС. iOS раньше macOS: User-Agent iPhone содержит "like Mac OS X"; Android раньше Linux
+//
+//nolint:gochecknoglobals
+var systems = []signature{
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"windows", "Windows"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"cros ", "ChromeOS"},
+	{"linux", "Linux"},
+}
+
+// Parse разбирает User-Agent
+func Parse(ua string) Info {
+	lower := strings.ToLower(ua)
+	if strings.TrimSpace(lower) == "" {
+		return Info{Browser: Other, OS: Other, Device: DeviceOther}
+	}
+
+	info := Info{
+		Browser: match(lower, browsers),
+		OS:      match(lower, systems),
+		Bot:     match(lower, bots),
+	}
+	if info.Bot == Other {
+		info.Bot = ""
+	}
+
+	switch {
+	case info.IsBot():
+		info.Device = DeviceBot
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		info.Device = DeviceTablet
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone") || strings.Contains(lower, "ipod"):
+		info.Device = DeviceMobile
+	default:
+		info.Device = DeviceDesktop
+	}
+
+	return info
+}
+
+// match возвращает имя первой подходящей сигнатуры либо Other
+func match(lower string, signatures []signature) string {
+	for _, sig := range signatures {
+		if strings.Contains(lower, sig.token) {
+			return sig.name
+		}
+	}
+	return Other
+}
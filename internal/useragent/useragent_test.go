@@ -0,0 +1,74 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Info
+	}{
+		{
+			name: "Chrome on Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want: Info{Browser: "Chrome", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			name: "Edge on Windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			want: Info{Browser: "Edge", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			name: "Safari on iPhone",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+			want: Info{Browser: "Safari", OS: "iOS", Device: DeviceMobile},
+		},
+		{
+			name: "Firefox on Android tablet",
+			ua:   "Mozilla/5.0 (Android 13; Tablet; rv:120.0) Gecko/120.0 Firefox/120.0",
+			want: Info{Browser: "Firefox", OS: "Android", Device: DeviceTablet},
+		},
+		{
+			name: "Chrome on Android phone",
+			ua:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			want: Info{Browser: "Chrome", OS: "Android", Device: DeviceMobile},
+		},
+		{
+			name: "Googlebot",
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: Info{Browser: Other, OS: Other, Device: DeviceBot, Bot: "Googlebot"},
+		},
+		{
+			name: "Telegram link preview",
+			ua:   "TelegramBot (like TwitterBot)",
+			want: Info{Browser: Other, OS: Other, Device: DeviceBot, Bot: "TelegramBot"},
+		},
+		{
+			name: "Slack link expander",
+			ua:   "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			want: Info{Browser: Other, OS: Other, Device: DeviceBot, Bot: "Slackbot"},
+		},
+		{
+			name: "curl",
+			ua:   "curl/8.4.0",
+			want: Info{Browser: Other, OS: Other, Device: DeviceBot, Bot: "Script"},
+		},
+		{
+			name: "Empty",
+			ua:   "",
+			want: Info{Browser: Other, OS: Other, Device: DeviceOther},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.ua)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.Bot != "", got.IsBot())
+		})
+	}
+}
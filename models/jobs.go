@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Статусы фоновой задачи
+const (
+	JobQueued  = "queued"  // Ждет воркера
+	JobRunning = "running" // Взята воркером
+	JobDone    = "done"    // Выполнена
+	JobFailed  = "failed"  // Завершилась ошибкой, текст в Error
+)
+
+// DeletionJob задача на удаление ссылок пользователя из очереди DELETE /api/user/urls
+type DeletionJob struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	ShortIDs  []string  `json:"short_ids"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Итог выполнения, заполняется при переходе в done
+	Deleted  int `json:"deleted"`
+	NotFound int `json:"not_found"`
+	NotOwned int `json:"not_owned"`
+}
+
+// JobResponse ответ GET /api/user/jobs/{id}
+type JobResponse struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"` // queued, running, done или failed
+	Deleted   int       `json:"deleted"`
+	NotFound  int       `json:"not_found"`
+	NotOwned  int       `json:"not_owned"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeleteResponse ответ DELETE /api/user/urls: идентификатор поставленной в очередь задачи.
+// Статус задачи доступен по GET /api/user/jobs/{id}
+type DeleteResponse struct {
+	JobID string `json:"job_id"`
+}
@@ -1,9 +1,18 @@
 // Package models содержит структуры запросов/ответов
 package models
 
+import "time"
+
 // ShortenRequest Объект, содержащий полный URL
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL   string `json:"url"`
+	Alias string `json:"alias,omitempty"` // Желаемый короткий идентификатор вместо сгенерированного (опционально)
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Момент, после которого ссылка отдает 410 (опционально)
+	TTL       int64      `json:"ttl,omitempty"`        // Время жизни в секундах, альтернатива expires_at (опционально)
+
+	Title        string `json:"title,omitempty"`        // Название ссылки для страницы предпросмотра (опционально)
+	Interstitial bool   `json:"interstitial,omitempty"` // Показывать промежуточную страницу вместо редиректа
 }
 
 // URLRecord Объект, хранящийся в файле-хранилище запросов. В идеальном мире должен быть заменен на URLResponse,
@@ -12,7 +21,18 @@ type URLRecord struct {
 	UUID        string `json:"uuid"`
 	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
-	DeletedFlag bool   `db:"is_deleted"`
+	DeletedFlag bool   `json:"is_deleted,omitempty" db:"is_deleted"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"` // nil - ссылка бессрочная
+	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at"` // nil у записей, созданных до появления поля
+
+	Title        string `json:"title,omitempty" db:"title"`
+	Interstitial bool   `json:"interstitial,omitempty" db:"interstitial"`
+}
+
+// Expired истек ли срок жизни ссылки к моменту now
+func (r URLRecord) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
 }
 
 // ShortenResponse Объект, содержащий сокращенный URL
@@ -31,6 +51,13 @@ type URLResponse struct {
 type BatchShortenRequest struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	Alias         string `json:"alias,omitempty"` // Желаемый короткий идентификатор (опционально)
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Момент истечения ссылки (опционально)
+	TTL       int64      `json:"ttl,omitempty"`        // Время жизни в секундах (опционально)
+
+	Title        string `json:"title,omitempty"`        // Название ссылки (опционально)
+	Interstitial bool   `json:"interstitial,omitempty"` // Промежуточная страница вместо редиректа (опционально)
 }
 
 // BatchShortenResponse В дальнейшем возможно будет использован для группировки сокращенных URL под одним ID.
@@ -38,4 +65,51 @@ type BatchShortenRequest struct {
 type BatchShortenResponse struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
+
+	// URL уже был сокращен ранее: short_url указывает на существующую ссылку, alias и остальные поля запроса не применены
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+// DailyClicks переходы по ссылке за одни сутки (UTC)
+type DailyClicks struct {
+	Date       string    `json:"date"` // YYYY-MM-DD
+	Clicks     int64     `json:"clicks"`
+	FirstClick time.Time `json:"first_click"`
+	LastClick  time.Time `json:"last_click"`
+}
+
+// LinkStats статистика переходов по ссылке для GET /api/user/urls/{id}/stats
+type LinkStats struct {
+	ShortURL    string        `json:"short_url"`
+	TotalClicks int64         `json:"total_clicks"`
+	FirstClick  *time.Time    `json:"first_click,omitempty"` // nil, если переходов не было
+	LastClick   *time.Time    `json:"last_click,omitempty"`
+	Daily       []DailyClicks `json:"daily"` // Гистограмма по дням в порядке возрастания даты
+}
+
+// Visit событие перехода по короткой ссылке
+type Visit struct {
+	ShortURL string    `json:"short_url"`
+	At       time.Time `json:"at"`
+	Referrer string    `json:"referrer,omitempty"` // Хост из заголовка Referer, пусто для прямых переходов
+	Browser  string    `json:"browser"`
+	OS       string    `json:"os"`
+	Device   string    `json:"device"`             // desktop, mobile, tablet, bot или other
+	Language string    `json:"language,omitempty"` // Основной язык из Accept-Language
+	IP       string    `json:"ip,omitempty"`       // Адрес клиента с обнуленной младшей частью
+	Bot      string    `json:"bot,omitempty"`      // Имя бота, пусто для людей
+}
+
+// VisitStats разбивка переходов по ссылке для GET /api/user/urls/{id}/visits.
+// Разбивки считаются только по людям, боты учитываются отдельно по именам
+type VisitStats struct {
+	ShortURL    string           `json:"short_url"`
+	HumanVisits int64            `json:"human_visits"`
+	BotVisits   int64            `json:"bot_visits"`
+	Referrers   map[string]int64 `json:"referrers"`
+	Browsers    map[string]int64 `json:"browsers"`
+	OS          map[string]int64 `json:"os"`
+	Devices     map[string]int64 `json:"devices"`
+	Languages   map[string]int64 `json:"languages"`
+	Bots        map[string]int64 `json:"bots"`
 }